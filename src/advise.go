@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	calc "airliner/calculation"
+	db "airliner/database"
+	md "airliner/model"
+	"airliner/notify"
+	tg "airliner/telegram"
+)
+
+// runAdvise implements "airliner advise": it estimates from the stored
+// history of a route whether to buy now or wait for a departure date.
+func runAdvise(args []string) {
+	fs := flag.NewFlagSet("advise", flag.ExitOnError)
+	var fromcity = fs.String("from", "", "3 letter upercase code for the city flying from.")
+	var tocity = fs.String("to", "", "3 letter upercase code for the city flying to.")
+	var departure = fs.String("departure", "", "departure date to get advice for, YYYY-MM-DD")
+	var days = fs.Int("days", 365, "number of days of history to use")
+	var tripmode = fs.String("trip-mode", md.RoundTrip, "trip mode of the offers to use: single, round or multi")
+	var currency = fs.String("currency", "", "currency of the prices to use (default the one of the newest offer)")
+	fs.Parse(args)
+
+	if *fromcity == "" || *tocity == "" || *departure == "" {
+		fmt.Println("ERROR arguments --from, --to and --departure are required")
+		return
+	}
+
+	departureDate, err := time.Parse("2006-01-02", *departure)
+	if err != nil {
+		fmt.Printf("ERROR Unable to parse --departure value '%s'. Format should be YYYY-MM-DD.\n", *departure)
+		return
+	}
+
+	client := db.InitDB("test_influxdb.env")
+	defer client.Close()
+
+	msg, err := createAdviceMessage(client, adviceQuery(*fromcity, *tocity, *tripmode, *currency, *days), departureDate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(msg)
+}
+
+func handleAdviseCommand(bot *tg.Bot, client db.DBClient, args []string) {
+	if len(args) < 3 {
+		tg.SendMessage(bot, "Usage: /advise FROM TO YYYY-MM-DD [single|round|multi]")
+		return
+	}
+	tripMode := md.RoundTrip
+	if len(args) > 3 {
+		tripMode = args[3]
+	}
+
+	departureDate, err := time.Parse("2006-01-02", args[2])
+	if err != nil {
+		tg.SendMessage(bot, fmt.Sprintf("Invalid departure date '%s'", args[2]))
+		return
+	}
+
+	msg, err := createAdviceMessage(client, adviceQuery(args[0], args[1], tripMode, "", 365), departureDate)
+	if err != nil {
+		notifyError(&notify.Telegram{Bot: bot}, err.Error())
+		return
+	}
+	tg.SendMessage(bot, msg)
+}
+
+// adviceQuery selects the history of a route to give advice with.
+func adviceQuery(from string, to string, tripMode string, currency string, days int) db.HistoryQuery {
+	return db.HistoryQuery{
+		FromAirport: strings.ToUpper(from),
+		ToAirport:   strings.ToUpper(to),
+		TripMode:    tripMode,
+		Currency:    strings.ToUpper(currency),
+		Since:       time.Now().Add(-time.Duration(days) * 24 * time.Hour),
+	}
+}
+
+// createAdviceMessage gives advice from the offers of q. Without a
+// currency in q, only the offers in the currency of the newest one are
+// compared.
+func createAdviceMessage(client db.DBClient, q db.HistoryQuery, departure time.Time) (string, error) {
+	from, to := q.FromAirport, q.ToAirport
+	offers, err := db.Read_offer_history(client, q, db.Bucket)
+	if err != nil {
+		return "", err
+	}
+	if q.Currency == "" {
+		offers = sameCurrency(offers)
+	}
+
+	observations := make([]calc.Observation, len(offers))
+	for i, o := range offers {
+		observations[i] = calc.Observation{
+			DepartureDate: o.DepartureDate,
+			ObservedOn:    o.CreatedOn,
+			Price:         o.Price,
+			Advice:        o.AdviceRecommendation,
+			AdviceDays:    o.AdviceWithinDays,
+		}
+	}
+
+	accuracy := ""
+	if correct, total := calc.PredictionAccuracy(observations); total > 0 {
+		accuracy = fmt.Sprintf("\nKayak's advice for this route was right %d of %d times.", correct, total)
+	}
+
+	daysToDeparture := int(time.Until(departure).Hours() / 24)
+	advice := calc.RecommendBooking(observations, daysToDeparture)
+	if advice == nil {
+		return fmt.Sprintf(
+			"Not enough history for %s-%s %d days before departure yet.%s", from, to, daysToDeparture, accuracy,
+		), nil
+	}
+
+	return fmt.Sprintf(
+		"%s-%s departing %s (%d days ahead), based on %d observations:\n%s%s",
+		from, to, departure.Format("2006-01-02"), daysToDeparture, len(observations), advice, accuracy,
+	), nil
+}
+
+// sameCurrency returns the offers in the currency of the newest one.
+// offers are sorted oldest first.
+func sameCurrency(offers []db.AirlineOffer) []db.AirlineOffer {
+	if len(offers) == 0 {
+		return offers
+	}
+
+	currency := offers[len(offers)-1].Currency
+	var same []db.AirlineOffer
+	for _, o := range offers {
+		if o.Currency == currency {
+			same = append(same, o)
+		}
+	}
+	return same
+}
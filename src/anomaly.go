@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	calc "airliner/calculation"
+	db "airliner/database"
+	md "airliner/model"
+	"airliner/notify"
+)
+
+// anomalyDetector flags offers far below the stored history of the
+// route and alerts about them right away instead of at the end of a run.
+type anomalyDetector struct {
+	notifier notify.Notifier
+	state    *notify.StateStore
+	// key is the searchKey of the search, alerts are remembered per
+	// search and offer dates.
+	key       string
+	history   []calc.Observation
+	threshold float64
+	l         *slog.Logger
+}
+
+// newAnomalyDetector compares offers with the stored offers of q, which
+// should only select offers of the same route, trip mode and currency.
+// key identifies the search like searchKey.
+func newAnomalyDetector(client db.DBClient, notifier notify.Notifier, state *notify.StateStore, key string, q db.HistoryQuery, threshold float64, l *slog.Logger) *anomalyDetector {
+	offers, err := db.Read_offer_history(client, q, db.Bucket)
+	if err != nil {
+		l.Warn("Couldn't read history, anomaly detection disabled", "err", err)
+		return nil
+	}
+
+	history := make([]calc.Observation, len(offers))
+	for i, o := range offers {
+		history[i] = calc.Observation{
+			DepartureDate: o.DepartureDate,
+			ObservedOn:    o.CreatedOn,
+			Price:         o.Price,
+		}
+	}
+
+	l.Info("Loaded historic offers for anomaly detection", "count", len(history))
+	return &anomalyDetector{notifier: notifier, state: state, key: key, history: history, threshold: threshold, l: l}
+}
+
+func (d *anomalyDetector) check(offer *md.Offer) {
+	a := calc.DetectAnomaly(offer.Price, offer.DepartureDate, d.history, d.threshold)
+	if a == nil {
+		return
+	}
+
+	offer.Anomalous = true
+	l := d.l.With("dates", offerDates(offer))
+	l.Warn("Possible error fare", "price", offer.Price, "median", a.Median, "score", a.Score)
+
+	key := "anomaly " + d.key + " " + offerDates(offer)
+	if !d.state.ShouldSend(key, offerDates(offer), offer.Price, time.Now()) {
+		l.Info("Error fare was already reported, not sending again")
+		return
+	}
+
+	notifyAnomaly(d.notifier, offer, a)
+	if err := d.state.Remember(key, offerDates(offer), offer.Price, time.Now()); err != nil {
+		l.Warn("Couldn't save notification state", "err", err)
+	}
+}
+
+func notifyAnomaly(n notify.Notifier, offer *md.Offer, a *calc.Anomaly) {
+	n.SendMessage(fmt.Sprintf(
+		"🚨 POSSIBLE ERROR FARE 🚨\n%s-%s %s for %s, usually %.2f (%.1f MADs below the median of %d offers for that month).\n%s",
+		offer.FromAirport,
+		offer.ToAirport,
+		offer.DepartureDate.Format("2006-01-02"),
+		priceString(offer),
+		a.Median,
+		a.Score,
+		a.Samples,
+		offer.Url,
+	))
+
+	sendScreenshot(n, offer)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	calc "airliner/calculation"
+	md "airliner/model"
+	"airliner/notify"
+)
+
+type fakeNotifier struct {
+	messages []string
+}
+
+func (n *fakeNotifier) SendMessage(text string) {
+	n.messages = append(n.messages, text)
+}
+
+func (n *fakeNotifier) SendImage(name string, reader io.Reader) {}
+
+func TestAnomalyDetectorDates(t *testing.T) {
+	state, err := notify.LoadStateStore(filepath.Join(t.TempDir(), "state.json"), 0.05, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	june := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	var history []calc.Observation
+	for i := 0; i < 20; i++ {
+		history = append(history, calc.Observation{DepartureDate: june.Add(time.Duration(i) * md.Day), Price: 300 + float64(i%5)})
+	}
+
+	n := &fakeNotifier{}
+	d := &anomalyDetector{notifier: n, state: state, key: "LIS-MUC [0] direct=true", history: history, threshold: 3, l: slog.Default()}
+
+	offers := func() []*md.Offer {
+		return []*md.Offer{
+			{FromAirport: "LIS", ToAirport: "MUC", DepartureDate: june.Add(4 * md.Day), Price: 90, FetchSuccessful: true},
+			{FromAirport: "LIS", ToAirport: "MUC", DepartureDate: june.Add(9 * md.Day), Price: 95, FetchSuccessful: true},
+		}
+	}
+
+	for run := 0; run < 2; run++ {
+		for _, o := range offers() {
+			d.check(o)
+			if !o.Anomalous {
+				t.Errorf("run %d: expected %s to be anomalous", run, offerDates(o))
+			}
+		}
+	}
+
+	// both dates are alerted once, the second run remembers them both
+	if len(n.messages) != 2 {
+		t.Errorf("expected 2 alerts, got %d: %v", len(n.messages), n.messages)
+	}
+}
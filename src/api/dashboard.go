@@ -0,0 +1,396 @@
+package api
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	db "airliner/database"
+	"airliner/digest"
+	"airliner/render"
+)
+
+// dashboardDays is how much stored history the dashboard looks at.
+const dashboardDays = 30
+
+// routeSummary is a row of the dashboard's route table. Route is the
+// route's path, Name tells the trip mode too.
+type routeSummary struct {
+	Route     string
+	Name      string
+	TripMode  string
+	Currency  string
+	LatestRun time.Time
+	Best      db.AirlineOffer
+	PeriodLow float64
+	Offers    int
+}
+
+// summarizeRoutes returns per route, trip mode and currency the best
+// offer of the latest run, taken as the offers stored within a day of the
+// newest one.
+func summarizeRoutes(offers []db.AirlineOffer) []routeSummary {
+	type key struct{ route, tripMode, currency string }
+	byRoute := make(map[key][]db.AirlineOffer)
+	for _, o := range offers {
+		k := key{o.FromAirport + "-" + o.ToAirport, o.TripMode, o.Currency}
+		byRoute[k] = append(byRoute[k], o)
+	}
+
+	var routes []routeSummary
+	for k, offers := range byRoute {
+		name := (&digest.Route{FromAirport: offers[0].FromAirport, ToAirport: offers[0].ToAirport, TripMode: k.tripMode}).Name()
+		r := routeSummary{Route: k.route, Name: name, TripMode: k.tripMode, Currency: k.currency, Offers: len(offers)}
+		for _, o := range offers {
+			if o.CreatedOn.After(r.LatestRun) {
+				r.LatestRun = o.CreatedOn
+			}
+			if r.PeriodLow == 0 || o.Price < r.PeriodLow {
+				r.PeriodLow = o.Price
+			}
+		}
+		for _, o := range offers {
+			if r.LatestRun.Sub(o.CreatedOn) > 24*time.Hour {
+				continue
+			}
+			if r.Best.Price == 0 || o.Price < r.Best.Price {
+				r.Best = o
+			}
+		}
+		routes = append(routes, r)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Name != routes[j].Name {
+			return routes[i].Name < routes[j].Name
+		}
+		return routes[i].Currency < routes[j].Currency
+	})
+	return routes
+}
+
+// failure is a failed offer of the dashboard, with a screenshot when it
+// was fetched by a search of this server.
+type failure struct {
+	Route      string
+	Dates      string
+	Url        string
+	CreatedOn  time.Time
+	Screenshot string
+}
+
+func (s *Server) recentFailures(stored []db.AirlineOffer) []failure {
+	var failures []failure
+
+	s.mu.Lock()
+	searches := append([]*Search{}, s.searches...)
+	s.mu.Unlock()
+
+	for _, search := range searches {
+		j := search.json(true)
+		for _, o := range j.Offers {
+			if o.Successful {
+				continue
+			}
+			failures = append(failures, failure{
+				Route:      o.From + "-" + o.To,
+				Dates:      strings.TrimSuffix(o.DepartureDate+" "+o.ReturnDate, " "),
+				Url:        o.Url,
+				CreatedOn:  o.CreatedOn,
+				Screenshot: o.Screenshot,
+			})
+		}
+	}
+
+	for _, o := range stored {
+		failures = append(failures, failure{
+			Route:     o.FromAirport + "-" + o.ToAirport,
+			Dates:     strings.TrimSuffix(formatDate(o.DepartureDate)+" "+formatDate(o.ReturnDate), " "),
+			Url:       o.Url,
+			CreatedOn: o.CreatedOn,
+		})
+	}
+
+	sort.SliceStable(failures, func(i, j int) bool { return failures[i].CreatedOn.After(failures[j].CreatedOn) })
+	if len(failures) > 20 {
+		failures = failures[:20]
+	}
+	return failures
+}
+
+func (s *Server) dashboard(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().Add(-dashboardDays * 24 * time.Hour)
+
+	offers, err := s.history.Offers(db.HistoryQuery{Since: since})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	failed, err := s.history.Failures(db.HistoryQuery{Since: time.Now().Add(-7 * 24 * time.Hour)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	searches := make([]searchJSON, len(s.searches))
+	for i, search := range s.searches {
+		searches[len(s.searches)-1-i] = search.json(false)
+	}
+	s.mu.Unlock()
+
+	var watches []Watch
+	if s.watches != nil {
+		watches = s.watches.All()
+	}
+
+	renderPage(w, dashboardTemplate, struct {
+		Days     int
+		Routes   []routeSummary
+		Searches []searchJSON
+		Failures []failure
+		Watches  []Watch
+		Watching bool
+		Interval time.Duration
+		Error    string
+	}{dashboardDays, summarizeRoutes(offers), searches, s.recentFailures(failed), watches, s.watches != nil, s.interval, r.URL.Query().Get("error")})
+}
+
+// splitRoute turns "LIS-MUC" into its airports.
+func splitRoute(route string) (string, string, bool) {
+	parts := strings.Split(strings.ToUpper(route), "-")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	q := db.HistoryQuery{FromAirport: parts[0], ToAirport: parts[1]}
+	if parts[0] == "" || parts[1] == "" || q.Validate() != nil {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func (s *Server) routePage(w http.ResponseWriter, route string) {
+	from, to, ok := splitRoute(route)
+	if !ok {
+		http.NotFound(w, nil)
+		return
+	}
+
+	offers, err := s.history.Offers(db.HistoryQuery{
+		FromAirport: from,
+		ToAirport:   to,
+		Since:       time.Now().Add(-dashboardDays * 24 * time.Hour),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sort.SliceStable(offers, func(i, j int) bool { return offers[i].CreatedOn.After(offers[j].CreatedOn) })
+	if len(offers) > 50 {
+		offers = offers[:50]
+	}
+
+	renderPage(w, routeTemplate, struct {
+		Name   string
+		Days   int
+		Offers []db.AirlineOffer
+	}{from + "-" + to, dashboardDays, offers})
+}
+
+func (s *Server) routeChart(w http.ResponseWriter, route string) {
+	from, to, ok := splitRoute(route)
+	if !ok {
+		http.NotFound(w, nil)
+		return
+	}
+
+	title, series, err := s.history.PriceHistory(from, to, dashboardDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	if err := render.WriteTrendSVG(w, title, series); err != nil {
+		log.Println(err)
+	}
+}
+
+// addWatchForm handles the dashboard's form. Empty fields are left out so
+// the search flags keep their defaults.
+func (s *Server) addWatchForm(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := make(map[string]string)
+	for name, values := range r.PostForm {
+		if v := strings.TrimSpace(values[0]); v != "" {
+			params[name] = v
+		}
+	}
+	if params["direct"] == "" {
+		params["direct"] = "false"
+	}
+
+	if _, err := s.addWatch(params); err != nil {
+		http.Redirect(w, r, "/?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func renderPage(w http.ResponseWriter, t *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		log.Println(err)
+	}
+}
+
+var funcs = template.FuncMap{
+	"date": formatDate,
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Local().Format("2006-01-02 15:04")
+	},
+}
+
+// layout has no external resources so the dashboard works offline.
+const layout = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Airliner</title>
+<style>
+body { font-family: sans-serif; margin: 1em auto; max-width: 960px; padding: 0 1em; color: #222 }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd }
+td.num { text-align: right }
+img.thumb { width: 160px; border: 1px solid #ccc }
+form label { display: inline-block; margin: 0 1em .5em 0 }
+.error { color: #b00 }
+.low { color: #080; font-weight: bold }
+</style>
+</head>
+<body>
+<h1><a href="/">Airliner</a></h1>
+{{template "content" .}}
+</body>
+</html>
+`
+
+var dashboardTemplate = template.Must(template.Must(template.New("dashboard").Funcs(funcs).Parse(layout)).Parse(`
+{{define "content"}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+
+<h2>Routes</h2>
+{{if .Routes}}
+<table>
+<tr><th>Route</th><th>Latest run</th><th>Best price</th><th>Dates</th><th>{{.Days}} day low</th><th>Offers</th></tr>
+{{range .Routes}}
+<tr>
+<td><a href="/routes/{{.Route}}">{{.Name}}</a></td>
+<td>{{time .LatestRun}}</td>
+<td class="num"><a href="{{.Best.Url}}">{{printf "%.2f" .Best.Price}} {{.Currency}}</a></td>
+<td>{{date .Best.DepartureDate}} {{date .Best.ReturnDate}}</td>
+<td class="num{{if eq .PeriodLow .Best.Price}} low{{end}}">{{printf "%.2f" .PeriodLow}} {{.Currency}}</td>
+<td class="num">{{.Offers}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No offers stored in the last {{.Days}} days.</p>
+{{end}}
+
+{{if .Watching}}
+<h2>Watches</h2>
+<p>Watched searches run every {{.Interval}}.</p>
+{{if .Watches}}
+<table>
+<tr><th>Route</th><th>Parameters</th><th>Last run</th></tr>
+{{range .Watches}}
+<tr><td>{{.Route}}</td><td>{{range $k, $v := .Params}}{{$k}}={{$v}} {{end}}</td><td>{{time .LastRun}}</td></tr>
+{{end}}
+</table>
+{{end}}
+<form method="post" action="/watches">
+<label>From <input name="from" size="4" placeholder="LIS"></label>
+<label>To <input name="to" size="4" placeholder="MUC"></label>
+<label>Look ahead <input name="look-ahead" size="3" value="30"></label>
+<label>Durations <input name="durations" size="8" placeholder="5,7"></label>
+<label>Start date <input name="start-date" size="10" placeholder="YYYY-MM-DD"></label>
+<label>Max. stops <input name="max-stops" size="2"></label>
+<label><input type="checkbox" name="direct" value="true" checked> Direct only</label>
+<button type="submit">Add watch</button>
+</form>
+{{end}}
+
+<h2>Searches</h2>
+{{if .Searches}}
+<table>
+<tr><th>Id</th><th>Status</th><th>Created</th><th>Offers</th><th>Failed</th><th>Best</th></tr>
+{{range .Searches}}
+<tr>
+<td><a href="/api/searches/{{.Id}}">{{.Id}}</a></td>
+<td>{{.Status}}{{if .Error}} <span class="error">{{.Error}}</span>{{end}}</td>
+<td>{{time .CreatedOn}}</td>
+<td class="num">{{.Fetched}}</td>
+<td class="num">{{.Failed}}</td>
+<td>{{with .Best}}<a href="{{.Url}}">{{printf "%.2f" .Price}}</a> {{.From}}-{{.To}} {{.DepartureDate}} {{.ReturnDate}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No searches since the server started.</p>
+{{end}}
+
+<h2>Recent failures</h2>
+{{if .Failures}}
+<table>
+<tr><th>When</th><th>Route</th><th>Dates</th><th>Debug screenshot</th></tr>
+{{range .Failures}}
+<tr>
+<td>{{time .CreatedOn}}</td>
+<td><a href="{{.Url}}">{{.Route}}</a></td>
+<td>{{.Dates}}</td>
+<td>{{if .Screenshot}}<a href="{{.Screenshot}}"><img class="thumb" src="{{.Screenshot}}" alt="screenshot"></a>{{else}}not kept{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No failures in the last 7 days.</p>
+{{end}}
+{{end}}
+`))
+
+var routeTemplate = template.Must(template.Must(template.New("route").Funcs(funcs).Parse(layout)).Parse(`
+{{define "content"}}
+<h2>{{.Name}}</h2>
+<p><img src="/routes/{{.Name}}/chart.svg" alt="price history of the last {{.Days}} days" style="max-width: 100%"></p>
+{{if .Offers}}
+<table>
+<tr><th>Stored</th><th>Departure</th><th>Return</th><th>Price</th><th>Kayak's advice</th></tr>
+{{range .Offers}}
+<tr>
+<td>{{time .CreatedOn}}</td>
+<td>{{date .DepartureDate}}</td>
+<td>{{date .ReturnDate}}</td>
+<td class="num"><a href="{{.Url}}">{{printf "%.2f" .Price}}</a>{{if .Anomaly}} <span class="low">error fare?</span>{{end}}</td>
+<td>{{.AdviceText}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No offers stored in the last {{.Days}} days.</p>
+{{end}}
+{{end}}
+`))
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	db "airliner/database"
+	md "airliner/model"
+)
+
+func TestSummarizeRoutes(t *testing.T) {
+	now := time.Date(2023, 6, 8, 12, 0, 0, 0, time.UTC)
+	offers := []db.AirlineOffer{
+		{FromAirport: "LIS", ToAirport: "MUC", TripMode: md.RoundTrip, Currency: "EUR", Price: 120, CreatedOn: now.Add(-72 * time.Hour)},
+		{FromAirport: "LIS", ToAirport: "MUC", TripMode: md.RoundTrip, Currency: "EUR", Price: 210, CreatedOn: now.Add(-time.Hour)},
+		{FromAirport: "LIS", ToAirport: "MUC", TripMode: md.RoundTrip, Currency: "EUR", Price: 190, CreatedOn: now},
+		{FromAirport: "LIS", ToAirport: "MUC", TripMode: md.RoundTrip, Currency: "USD", Price: 150, CreatedOn: now},
+		{FromAirport: "LIS", ToAirport: "MUC", TripMode: md.SingleTrip, Currency: "EUR", Price: 60, CreatedOn: now},
+		{FromAirport: "BER", ToAirport: "LIS", TripMode: md.RoundTrip, Currency: "EUR", Price: 90, CreatedOn: now},
+	}
+
+	routes := summarizeRoutes(offers)
+
+	tests := []struct {
+		name     string
+		currency string
+		best     float64
+		low      float64
+		offers   int
+	}{
+		{"BER-LIS", "EUR", 90, 90, 1},
+		{"LIS-MUC", "EUR", 190, 120, 3},
+		{"LIS-MUC", "USD", 150, 150, 1},
+		{"LIS-MUC one-way", "EUR", 60, 60, 1},
+	}
+
+	if len(routes) != len(tests) {
+		t.Fatalf("expected %d routes, got %d", len(tests), len(routes))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name+" "+tt.currency, func(t *testing.T) {
+			r := routes[i]
+			if r.Name != tt.name || r.Currency != tt.currency || r.Best.Price != tt.best || r.PeriodLow != tt.low || r.Offers != tt.offers || !r.LatestRun.Equal(now) {
+				t.Errorf("unexpected route %+v", r)
+			}
+		})
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	dir := t.TempDir()
+	history := fakeHistory{
+		{FromAirport: "LIS", ToAirport: "MUC", Price: 150, DepartureDate: time.Date(2023, 6, 5, 0, 0, 0, 0, time.UTC), CreatedOn: time.Now()},
+	}
+	runner := &fakeRunner{dir: dir, release: make(chan bool, 10)}
+
+	s := NewServer(runner, history, filepath.Join(dir, "screenshots"), 5)
+	watches, err := LoadWatchStore(filepath.Join(dir, "watches.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.watches = watches
+	s.interval = time.Hour
+
+	form := url.Values{"from": {"LIS"}, "to": {"MUC"}, "look-ahead": {"7"}, "durations": {""}}
+	req := httptest.NewRequest(http.MethodPost, "/watches", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/" {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	all := watches.All()
+	if len(all) != 1 || all[0].Route() != "LIS-MUC" || all[0].Params["direct"] != "false" || all[0].LastRun.IsZero() {
+		t.Fatalf("unexpected watches %+v", all)
+	}
+	if _, ok := all[0].Params["durations"]; ok {
+		t.Errorf("empty field should be left out: %v", all[0].Params)
+	}
+
+	reloaded, err := LoadWatchStore(filepath.Join(dir, "watches.json"))
+	if err != nil || len(reloaded.All()) != 1 {
+		t.Errorf("watches weren't saved: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		want        []string
+	}{
+		{"dashboard", "/", "text/html", []string{`<a href="/routes/LIS-MUC">LIS-MUC</a>`, "150.00", "look-ahead=7", "Add watch", `<a href="/api/searches/1">1</a>`}},
+		{"route", "/routes/lis-muc", "text/html", []string{"<h2>LIS-MUC</h2>", "/routes/LIS-MUC/chart.svg", "2023-06-05"}},
+		{"chart", "/routes/LIS-MUC/chart.svg", "image/svg+xml", []string{"<svg", "Price history LIS-MUC"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), tt.contentType) {
+				t.Fatalf("unexpected response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(rec.Body.String(), w) {
+					t.Errorf("expected %q in page:\n%s", w, rec.Body.String())
+				}
+			}
+		})
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, `/routes/LIS-MUC%22)%20or%20(true`, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for an invalid route, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	form = url.Values{"to": {"MUC"}}
+	req = httptest.NewRequest(http.MethodPost, "/watches", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if !strings.HasPrefix(rec.Header().Get("Location"), "/?error=") {
+		t.Errorf("expected error redirect, got %s", rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	db "airliner/database"
+	md "airliner/model"
+)
+
+const dateFormat = "2006-01-02"
+
+type legJSON struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Departure time.Time `json:"departure,omitempty"`
+	Arrival   time.Time `json:"arrival,omitempty"`
+	Duration  string    `json:"duration,omitempty"`
+	Stops     int       `json:"stops"`
+	Layovers  []string  `json:"layovers,omitempty"`
+	Airlines  []string  `json:"airlines,omitempty"`
+}
+
+type offerJSON struct {
+	Url              string    `json:"url"`
+	From             string    `json:"from"`
+	To               string    `json:"to"`
+	DepartureDate    string    `json:"departureDate"`
+	ReturnDate       string    `json:"returnDate,omitempty"`
+	Price            float64   `json:"price"`
+	Currency         string    `json:"currency,omitempty"`
+	Market           string    `json:"market,omitempty"`
+	OriginalPrice    float64   `json:"originalPrice,omitempty"`
+	OriginalCurrency string    `json:"originalCurrency,omitempty"`
+	CreatedOn        time.Time `json:"createdOn"`
+	Successful       bool      `json:"successful"`
+	Excluded         bool      `json:"excluded,omitempty"`
+	Anomalous        bool      `json:"anomalous,omitempty"`
+	Attempts         int       `json:"attempts,omitempty"`
+	ReadyRetries     int       `json:"readyRetries,omitempty"`
+	Advice           string    `json:"advice,omitempty"`
+	Legs             []legJSON `json:"legs,omitempty"`
+	Screenshot       string    `json:"screenshot,omitempty"`
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateFormat)
+}
+
+// newOfferJSON converts an offer fetched by a search. screenshot is the
+// url the offer's screenshot is served at.
+func newOfferJSON(o *md.Offer, screenshot string) offerJSON {
+	j := offerJSON{
+		Url:              o.Url,
+		From:             o.FromAirport,
+		To:               o.ToAirport,
+		DepartureDate:    formatDate(o.DepartureDate),
+		ReturnDate:       formatDate(o.ReturnDate),
+		Price:            o.Price,
+		Currency:         o.Currency,
+		Market:           o.Market,
+		OriginalPrice:    o.OriginalPrice,
+		OriginalCurrency: o.OriginalCurrency,
+		CreatedOn:        o.CreatedOn,
+		Successful:       o.FetchSuccessful,
+		Excluded:         o.Excluded,
+		Anomalous:        o.Anomalous,
+		Attempts:         o.Attempts,
+		ReadyRetries:     o.ReadyRetries,
+		Screenshot:       screenshot,
+	}
+	if o.Advice != nil {
+		j.Advice = o.Advice.String()
+	}
+
+	if o.Itinerary != nil {
+		for _, l := range o.Itinerary.Legs {
+			leg := legJSON{
+				From:      l.FromAirport,
+				To:        l.ToAirport,
+				Departure: l.Departure,
+				Arrival:   l.Arrival,
+				Stops:     l.Stops,
+				Airlines:  l.Airlines,
+			}
+			if l.Duration > 0 {
+				leg.Duration = l.Duration.String()
+			}
+			for _, lo := range l.Layovers {
+				leg.Layovers = append(leg.Layovers, lo.Airport)
+			}
+			j.Legs = append(j.Legs, leg)
+		}
+	}
+
+	return j
+}
+
+type storedOfferJSON struct {
+	Url              string    `json:"url"`
+	From             string    `json:"from"`
+	To               string    `json:"to"`
+	DepartureDate    string    `json:"departureDate"`
+	ReturnDate       string    `json:"returnDate,omitempty"`
+	Price            float64   `json:"price"`
+	Currency         string    `json:"currency,omitempty"`
+	Market           string    `json:"market,omitempty"`
+	OriginalPrice    float64   `json:"originalPrice,omitempty"`
+	OriginalCurrency string    `json:"originalCurrency,omitempty"`
+	CreatedOn        time.Time `json:"createdOn"`
+	Advice           string    `json:"advice,omitempty"`
+	Anomalous        bool      `json:"anomalous,omitempty"`
+}
+
+func newStoredOfferJSON(o *db.AirlineOffer) storedOfferJSON {
+	return storedOfferJSON{
+		Url:              o.Url,
+		From:             o.FromAirport,
+		To:               o.ToAirport,
+		DepartureDate:    formatDate(o.DepartureDate),
+		ReturnDate:       formatDate(o.ReturnDate),
+		Price:            o.Price,
+		Currency:         o.Currency,
+		Market:           o.Market,
+		OriginalPrice:    o.OriginalPrice,
+		OriginalCurrency: o.OriginalCurrency,
+		CreatedOn:        o.CreatedOn,
+		Advice:           o.AdviceText,
+		Anomalous:        o.Anomaly,
+	}
+}
+
+type searchJSON struct {
+	Id         string            `json:"id"`
+	Status     string            `json:"status"`
+	Params     map[string]string `json:"params"`
+	WatchId    string            `json:"watch,omitempty"`
+	CreatedOn  time.Time         `json:"createdOn"`
+	StartedOn  *time.Time        `json:"startedOn,omitempty"`
+	FinishedOn *time.Time        `json:"finishedOn,omitempty"`
+	Fetched    int               `json:"fetched"`
+	Failed     int               `json:"failed"`
+	Error      string            `json:"error,omitempty"`
+	Best       *offerJSON        `json:"best,omitempty"`
+	Offers     []offerJSON       `json:"offers,omitempty"`
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func screenshotUrl(searchId string, index int) string {
+	return fmt.Sprintf("/api/searches/%s/screenshots/%d", searchId, index)
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	md "airliner/model"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Runner performs searches for the server.
+type Runner interface {
+	// Validate checks the parameters, named like the command line flags,
+	// before a search is queued.
+	Validate(params map[string]string) error
+	// Run performs the search with the given id, calling progress with
+	// every fetched offer, and returns the best offer or nil when there is
+	// none.
+	Run(id string, params map[string]string, progress func(*md.Offer)) *md.Offer
+}
+
+// Search is a search started through the API.
+type Search struct {
+	mu sync.Mutex
+
+	Id         string
+	Status     string
+	Params     map[string]string
+	WatchId    string
+	CreatedOn  time.Time
+	StartedOn  time.Time
+	FinishedOn time.Time
+	Error      string
+	Offers     []*md.Offer
+	Best       *md.Offer
+
+	// dir keeps the screenshots of the offers, as the pipeline names
+	// them after the payload and would overwrite them in the next search.
+	dir string
+}
+
+// addOffer moves the offer's screenshot into the search's directory and
+// records the offer.
+func (s *Search) addOffer(o *md.Offer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if o.Screenshot != "" {
+		fname := filepath.Join(s.dir, fmt.Sprintf("%d.png", len(s.Offers)))
+		if err := os.Rename(o.Screenshot, fname); err != nil {
+			log.Printf("Couldn't keep screenshot %s: %s\n", o.Screenshot, err)
+		} else {
+			o.Screenshot = fname
+		}
+	}
+	s.Offers = append(s.Offers, o)
+}
+
+func (s *Search) json(withOffers bool) searchJSON {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j := searchJSON{
+		Id:         s.Id,
+		Status:     s.Status,
+		Params:     s.Params,
+		WatchId:    s.WatchId,
+		CreatedOn:  s.CreatedOn,
+		StartedOn:  timePtr(s.StartedOn),
+		FinishedOn: timePtr(s.FinishedOn),
+		Error:      s.Error,
+	}
+
+	for i, o := range s.Offers {
+		if o.FetchSuccessful {
+			j.Fetched++
+		} else {
+			j.Failed++
+		}
+		if withOffers {
+			j.Offers = append(j.Offers, newOfferJSON(o, screenshotUrl(s.Id, i)))
+		}
+		if o == s.Best {
+			best := newOfferJSON(o, screenshotUrl(s.Id, i))
+			j.Best = &best
+		}
+	}
+
+	return j
+}
+
+func (s *Search) screenshot(index int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if index < 0 || index >= len(s.Offers) || s.Offers[index].Screenshot == "" {
+		return "", false
+	}
+	return s.Offers[index].Screenshot, true
+}
+
+func (s *Search) setStatus(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Status = status
+	switch status {
+	case StatusRunning:
+		s.StartedOn = time.Now()
+	case StatusDone, StatusFailed:
+		s.FinishedOn = time.Now()
+	}
+}
+
+func (s *Search) setError(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Error = msg
+}
+
+// run performs the search, turning a panic of the pipeline into a
+// failed search instead of stopping the server.
+func (s *Search) run(runner Runner) {
+	s.setStatus(StatusRunning)
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.setError(fmt.Sprint(r))
+			s.setStatus(StatusFailed)
+		}
+	}()
+
+	best := runner.Run(s.Id, s.Params, s.addOffer)
+
+	s.mu.Lock()
+	s.Best = best
+	if best == nil {
+		s.Error = "no offer found"
+	}
+	s.mu.Unlock()
+	s.setStatus(StatusDone)
+}
@@ -0,0 +1,440 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	db "airliner/database"
+	"airliner/render"
+)
+
+// History reads stored offers.
+type History interface {
+	Offers(q db.HistoryQuery) ([]db.AirlineOffer, error)
+	Failures(q db.HistoryQuery) ([]db.AirlineOffer, error)
+	// PriceHistory returns the chart title and one series per departure
+	// date of the route's last days.
+	PriceHistory(from string, to string, days int) (string, []render.Series, error)
+}
+
+// Server exposes searches and stored offers over HTTP:
+//
+//	POST /api/searches                          start a search
+//	GET  /api/searches                          list searches
+//	GET  /api/searches/{id}                     status and results of a search
+//	GET  /api/searches/{id}/screenshots/{n}     screenshot of the n-th offer
+//	GET  /api/offers                            stored offers
+//	GET  /api/watches                           list watches
+//	POST /api/watches                           add a watch
+//
+// and a dashboard at "/". Searches run one at a time in the order they
+// were started.
+type Server struct {
+	runner  Runner
+	history History
+	dir     string
+	keep    int
+
+	watches  *WatchStore
+	interval time.Duration
+	// dueMu keeps a watch from being queued twice before it's marked run.
+	dueMu sync.Mutex
+
+	mu       sync.Mutex
+	searches []*Search
+	nextId   int
+	queue    chan *Search
+}
+
+// NewServer creates a server keeping the screenshots of the last keep
+// searches in dir.
+func NewServer(runner Runner, history History, dir string, keep int) *Server {
+	s := &Server{
+		runner:  runner,
+		history: history,
+		dir:     dir,
+		keep:    keep,
+		queue:   make(chan *Search, 100),
+	}
+	go s.work()
+	return s
+}
+
+func (s *Server) work() {
+	for search := range s.queue {
+		log.Printf("Running search %s\n", search.Id)
+		search.run(s.runner)
+		log.Printf("Search %s finished with status %s\n", search.Id, search.Status)
+	}
+}
+
+// Watch repeats the watches of store every interval.
+func (s *Server) Watch(store *WatchStore, interval time.Duration) {
+	s.watches = store
+	s.interval = interval
+
+	go func() {
+		for {
+			s.runDueWatches()
+			time.Sleep(time.Minute)
+		}
+	}()
+}
+
+// runDueWatches queues the due watches. A watch only counts as run once
+// its search was queued, so one that couldn't be is retried next time.
+func (s *Server) runDueWatches() {
+	s.dueMu.Lock()
+	defer s.dueMu.Unlock()
+
+	now := time.Now()
+	for _, w := range s.watches.Due(s.interval, now) {
+		if _, err := s.queueSearch(w.Params, w.Id); err != nil {
+			log.Printf("Couldn't run watch %s: %s\n", w.Id, err)
+			continue
+		}
+		if err := s.watches.MarkRun(w.Id, now); err != nil {
+			log.Printf("Couldn't save watches: %s\n", err)
+		}
+	}
+}
+
+// addWatch validates and stores a watch, then runs it right away.
+func (s *Server) addWatch(params map[string]string) (Watch, error) {
+	if s.watches == nil {
+		return Watch{}, errors.New("watches are disabled")
+	}
+	if err := s.runner.Validate(params); err != nil {
+		return Watch{}, err
+	}
+
+	w, err := s.watches.Add(params, time.Now())
+	if err != nil {
+		return w, err
+	}
+	s.runDueWatches()
+	return w, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if parts[0] != "api" {
+		switch {
+		case parts[0] == "" && r.Method == http.MethodGet:
+			s.dashboard(w, r)
+		case parts[0] == "routes" && len(parts) == 2 && r.Method == http.MethodGet:
+			s.routePage(w, parts[1])
+		case parts[0] == "routes" && len(parts) == 3 && parts[2] == "chart.svg" && r.Method == http.MethodGet:
+			s.routeChart(w, parts[1])
+		case parts[0] == "watches" && len(parts) == 1 && r.Method == http.MethodPost:
+			s.addWatchForm(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+
+	if len(parts) < 2 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch {
+	case parts[1] == "searches" && len(parts) == 2 && r.Method == http.MethodPost:
+		s.startSearch(w, r)
+	case parts[1] == "searches" && len(parts) == 2 && r.Method == http.MethodGet:
+		s.listSearches(w)
+	case parts[1] == "searches" && len(parts) == 3 && r.Method == http.MethodGet:
+		s.getSearch(w, parts[2])
+	case parts[1] == "searches" && len(parts) == 5 && parts[3] == "screenshots" && r.Method == http.MethodGet:
+		s.getScreenshot(w, r, parts[2], parts[4])
+	case parts[1] == "offers" && len(parts) == 2 && r.Method == http.MethodGet:
+		s.listOffers(w, r)
+	case parts[1] == "watches" && len(parts) == 2 && r.Method == http.MethodGet:
+		s.listWatches(w)
+	case parts[1] == "watches" && len(parts) == 2 && r.Method == http.MethodPost:
+		s.startWatch(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// decodeParams reads a JSON object of search parameters. Values may be
+// strings, numbers or booleans.
+func decodeParams(r *http.Request) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %s", err)
+	}
+
+	params := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v.(type) {
+		case string, float64, bool:
+			params[k] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("invalid value for parameter '%s'", k)
+		}
+	}
+	return params, nil
+}
+
+func (s *Server) startSearch(w http.ResponseWriter, r *http.Request) {
+	params, err := decodeParams(r)
+	if err == nil {
+		err = s.runner.Validate(params)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	search, err := s.queueSearch(params, "")
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/api/searches/"+search.Id)
+	writeJSON(w, http.StatusAccepted, search.json(false))
+}
+
+func (s *Server) queueSearch(params map[string]string, watchId string) (*Search, error) {
+	search, err := s.addSearch(params, watchId)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.queue <- search:
+		return search, nil
+	default:
+		search.setError("too many queued searches")
+		search.setStatus(StatusFailed)
+		return nil, errors.New("too many queued searches")
+	}
+}
+
+// addSearch registers a new search and forgets the oldest finished ones
+// beyond keep, removing their screenshots.
+func (s *Server) addSearch(params map[string]string, watchId string) (*Search, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextId++
+	search := &Search{
+		Id:        strconv.Itoa(s.nextId),
+		Status:    StatusQueued,
+		Params:    params,
+		WatchId:   watchId,
+		CreatedOn: time.Now(),
+	}
+	search.dir = filepath.Join(s.dir, search.Id)
+	if err := os.MkdirAll(search.dir, 0o755); err != nil {
+		return nil, err
+	}
+	s.searches = append(s.searches, search)
+
+	for len(s.searches) > s.keep {
+		old := s.searches[0]
+		if st := old.json(false).Status; st != StatusDone && st != StatusFailed {
+			break
+		}
+		if err := os.RemoveAll(old.dir); err != nil {
+			log.Println(err)
+		}
+		s.searches = s.searches[1:]
+	}
+
+	return search, nil
+}
+
+func (s *Server) listWatches(w http.ResponseWriter) {
+	if s.watches == nil {
+		writeError(w, http.StatusNotFound, "watches are disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.watches.All())
+}
+
+func (s *Server) startWatch(w http.ResponseWriter, r *http.Request) {
+	params, err := decodeParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	watch, err := s.addWatch(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, watch)
+}
+
+func (s *Server) findSearch(id string) *Search {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, search := range s.searches {
+		if search.Id == id {
+			return search
+		}
+	}
+	return nil
+}
+
+func (s *Server) listSearches(w http.ResponseWriter) {
+	s.mu.Lock()
+	searches := make([]searchJSON, len(s.searches))
+	for i, search := range s.searches {
+		searches[i] = search.json(false)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, searches)
+}
+
+func (s *Server) getSearch(w http.ResponseWriter, id string) {
+	search := s.findSearch(id)
+	if search == nil {
+		writeError(w, http.StatusNotFound, "unknown search")
+		return
+	}
+	writeJSON(w, http.StatusOK, search.json(true))
+}
+
+func (s *Server) getScreenshot(w http.ResponseWriter, r *http.Request, id string, index string) {
+	search := s.findSearch(id)
+	if search == nil {
+		writeError(w, http.StatusNotFound, "unknown search")
+		return
+	}
+
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid screenshot index")
+		return
+	}
+
+	fname, ok := search.screenshot(i)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown screenshot")
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeFile(w, r, fname)
+}
+
+// listOffers returns stored offers, newest first. It takes the query
+// parameters from, to, days (default 30), departure-from and departure-to
+// (YYYY-MM-DD), max-price and limit.
+func (s *Server) listOffers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := 30
+	if v := query.Get("days"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid days")
+			return
+		}
+		days = d
+	}
+
+	var departureFrom, departureTo time.Time
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"departure-from", &departureFrom}, {"departure-to", &departureTo}} {
+		if v := query.Get(p.name); v != "" {
+			t, err := time.Parse(dateFormat, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+p.name)
+				return
+			}
+			*p.t = t
+		}
+	}
+
+	maxPrice := 0.0
+	if v := query.Get("max-price"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid max-price")
+			return
+		}
+		maxPrice = p
+	}
+
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = l
+	}
+
+	q := db.HistoryQuery{
+		FromAirport: strings.ToUpper(query.Get("from")),
+		ToAirport:   strings.ToUpper(query.Get("to")),
+		Since:       time.Now().Add(-time.Duration(days) * 24 * time.Hour),
+	}
+	if err := q.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	offers, err := s.history.Offers(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := []storedOfferJSON{}
+	for i := range offers {
+		o := &offers[i]
+		if !departureFrom.IsZero() && o.DepartureDate.Before(departureFrom) {
+			continue
+		}
+		if !departureTo.IsZero() && o.DepartureDate.After(departureTo) {
+			continue
+		}
+		if maxPrice > 0 && o.Price > maxPrice {
+			continue
+		}
+		result = append(result, newStoredOfferJSON(o))
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].CreatedOn.After(result[j].CreatedOn) })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
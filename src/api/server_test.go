@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	db "airliner/database"
+	md "airliner/model"
+	"airliner/render"
+)
+
+type fakeRunner struct {
+	dir     string
+	release chan bool
+}
+
+func (f *fakeRunner) Validate(params map[string]string) error {
+	if params["from"] == "" {
+		return errors.New("argument --from not supplied")
+	}
+	return nil
+}
+
+func (f *fakeRunner) Run(id string, params map[string]string, progress func(*md.Offer)) *md.Offer {
+	<-f.release
+
+	var best *md.Offer
+	for i, price := range []float64{300, 250} {
+		fname := filepath.Join(f.dir, "payload.png")
+		os.WriteFile(fname, []byte{byte(i)}, 0o644)
+
+		o := &md.Offer{FromAirport: params["from"], Price: price, Screenshot: fname, FetchSuccessful: true}
+		progress(o)
+		best = o
+	}
+	return best
+}
+
+type fakeHistory []db.AirlineOffer
+
+func (h fakeHistory) Offers(q db.HistoryQuery) ([]db.AirlineOffer, error) {
+	var offers []db.AirlineOffer
+	for _, o := range h {
+		if q.FromAirport == "" || o.FromAirport == q.FromAirport {
+			offers = append(offers, o)
+		}
+	}
+	return offers, nil
+}
+
+func (h fakeHistory) Failures(q db.HistoryQuery) ([]db.AirlineOffer, error) {
+	return nil, nil
+}
+
+func (h fakeHistory) PriceHistory(from string, to string, days int) (string, []render.Series, error) {
+	var series []render.Series
+	for _, o := range h {
+		if o.FromAirport == from && o.ToAirport == to {
+			series = append(series, render.Series{
+				Label:  o.DepartureDate.Format("2006-01-02"),
+				Points: []render.Point{{Time: o.CreatedOn, Price: o.Price}},
+			})
+		}
+	}
+	return "Price history " + from + "-" + to, series, nil
+}
+
+func request(t *testing.T, s *Server, method string, path string, body string, v interface{}) int {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("invalid response %q: %s", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	runner := &fakeRunner{dir: dir, release: make(chan bool)}
+	s := NewServer(runner, fakeHistory{}, filepath.Join(dir, "screenshots"), 5)
+
+	var search searchJSON
+	if code := request(t, s, http.MethodPost, "/api/searches", `{"from": "LIS", "look-ahead": 7, "direct": false}`, &search); code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, code)
+	}
+	if search.Params["look-ahead"] != "7" || search.Params["direct"] != "false" {
+		t.Errorf("unexpected params %v", search.Params)
+	}
+
+	runner.release <- true
+
+	path := "/api/searches/" + search.Id
+	for i := 0; i < 100 && search.Status != StatusDone; i++ {
+		time.Sleep(10 * time.Millisecond)
+		request(t, s, http.MethodGet, path, "", &search)
+	}
+
+	if search.Status != StatusDone || len(search.Offers) != 2 || search.Best == nil || search.Best.Price != 250 {
+		t.Fatalf("unexpected search %+v", search)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, search.Offers[0].Screenshot, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "\x00" {
+		t.Errorf("unexpected screenshot %d %q", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"invalid params", http.MethodPost, "/api/searches", `{"to": "MUC"}`, http.StatusBadRequest},
+		{"invalid JSON", http.MethodPost, "/api/searches", `{`, http.StatusBadRequest},
+		{"unknown search", http.MethodGet, "/api/searches/42", "", http.StatusNotFound},
+		{"unknown screenshot", http.MethodGet, path + "/screenshots/2", "", http.StatusNotFound},
+		{"unknown path", http.MethodGet, "/api/unknown", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := request(t, s, tt.method, tt.path, tt.body, nil); code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, code)
+			}
+		})
+	}
+}
+
+func TestListOffers(t *testing.T) {
+	now := time.Now()
+	history := fakeHistory{
+		{FromAirport: "LIS", ToAirport: "MUC", Price: 200, DepartureDate: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), CreatedOn: now.Add(-2 * time.Hour)},
+		{FromAirport: "LIS", ToAirport: "MUC", Price: 150, DepartureDate: time.Date(2023, 6, 5, 0, 0, 0, 0, time.UTC), CreatedOn: now.Add(-time.Hour)},
+		{FromAirport: "BER", ToAirport: "LIS", Price: 90, DepartureDate: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), CreatedOn: now},
+	}
+	s := NewServer(&fakeRunner{}, history, t.TempDir(), 5)
+
+	tests := []struct {
+		name   string
+		query  string
+		prices []float64
+	}{
+		{"all, newest first", "", []float64{90, 150, 200}},
+		{"by route", "?from=lis", []float64{150, 200}},
+		{"by departure", "?departure-from=2023-06-02", []float64{150}},
+		{"by price", "?max-price=160", []float64{90, 150}},
+		{"limited", "?limit=1", []float64{90}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var offers []storedOfferJSON
+			if code := request(t, s, http.MethodGet, "/api/offers"+tt.query, "", &offers); code != http.StatusOK {
+				t.Fatalf("unexpected status %d", code)
+			}
+			if len(offers) != len(tt.prices) {
+				t.Fatalf("expected %d offers, got %d", len(tt.prices), len(offers))
+			}
+			for i, p := range tt.prices {
+				if offers[i].Price != p {
+					t.Errorf("expected price %.2f at %d, got %.2f", p, i, offers[i].Price)
+				}
+			}
+		})
+	}
+
+	for _, query := range []string{"?days=x", `?from=LIS"%20or%20true%20or%20"`} {
+		if code := request(t, s, http.MethodGet, "/api/offers"+query, "", nil); code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, code)
+		}
+	}
+}
+
+func TestRunDueWatches(t *testing.T) {
+	watches, err := LoadWatchStore(filepath.Join(t.TempDir(), "watches.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := watches.Add(map[string]string{"from": "LIS", "to": "MUC"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a worker and room in the queue, the search can't be queued
+	s := &Server{runner: &fakeRunner{}, dir: t.TempDir(), keep: 5, queue: make(chan *Search), watches: watches, interval: time.Hour}
+	s.runDueWatches()
+	if due := watches.Due(time.Hour, time.Now()); len(due) != 1 || !due[0].LastRun.IsZero() {
+		t.Fatalf("expected the watch to stay due, got %+v", due)
+	}
+
+	s.queue = make(chan *Search, 1)
+	s.runDueWatches()
+	if due := watches.Due(time.Hour, time.Now()); len(due) != 0 {
+		t.Errorf("expected watch %s to be marked run, got %+v", w.Id, due)
+	}
+	if len(s.queue) != 1 {
+		t.Errorf("expected 1 queued search, got %d", len(s.queue))
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Watch is a search the server repeats at a fixed interval.
+type Watch struct {
+	Id        string            `json:"id"`
+	Params    map[string]string `json:"params"`
+	CreatedOn time.Time         `json:"createdOn"`
+	LastRun   time.Time         `json:"lastRun"`
+}
+
+// Route returns the route searched by the watch, e.g. "LIS-MUC", or the
+// legs of a multi-city watch.
+func (w *Watch) Route() string {
+	if legs := w.Params["legs"]; legs != "" {
+		return legs
+	}
+	return w.Params["from"] + "-" + w.Params["to"]
+}
+
+// WatchStore keeps the watches in a JSON file.
+type WatchStore struct {
+	path    string
+	mu      sync.Mutex
+	watches map[string]*Watch
+}
+
+// LoadWatchStore reads the watch file at path. A missing file starts an
+// empty store.
+func LoadWatchStore(path string) (*WatchStore, error) {
+	s := &WatchStore{
+		path:    path,
+		watches: make(map[string]*Watch),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.watches); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// All returns copies of the watches, oldest first.
+func (s *WatchStore) All() []Watch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	watches := make([]Watch, 0, len(s.watches))
+	for _, w := range s.watches {
+		watches = append(watches, *w)
+	}
+	sort.Slice(watches, func(i, j int) bool { return watches[i].CreatedOn.Before(watches[j].CreatedOn) })
+	return watches
+}
+
+// Add stores a new watch and writes the watch file.
+func (s *WatchStore) Add(params map[string]string, now time.Time) (Watch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := len(s.watches) + 1
+	for s.watches[strconv.Itoa(id)] != nil {
+		id++
+	}
+
+	w := &Watch{Id: strconv.Itoa(id), Params: params, CreatedOn: now}
+	s.watches[w.Id] = w
+	return *w, s.save()
+}
+
+// Due returns the watches that didn't run for interval, oldest first.
+func (s *WatchStore) Due(interval time.Duration, now time.Time) []Watch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Watch
+	for _, w := range s.watches {
+		if now.Sub(w.LastRun) >= interval {
+			due = append(due, *w)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedOn.Before(due[j].CreatedOn) })
+	return due
+}
+
+// MarkRun records that the watch ran at now and writes the watch file.
+func (s *WatchStore) MarkRun(id string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := s.watches[id]
+	if w == nil {
+		return fmt.Errorf("unknown watch %s", id)
+	}
+	w.LastRun = now
+	return s.save()
+}
+
+func (s *WatchStore) save() error {
+	data, err := json.MarshalIndent(s.watches, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o644)
+}
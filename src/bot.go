@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+
+	db "airliner/database"
+	tg "airliner/telegram"
+)
+
+// runBot implements "airliner bot": it answers Telegram commands until
+// the process is stopped.
+func runBot(args []string) {
+	fs := flag.NewFlagSet("bot", flag.ExitOnError)
+	fs.Parse(args)
+
+	client := db.InitDB("test_influxdb.env")
+	defer client.Close()
+
+	godotenv.Load()
+	bot, err := tg.InitBot()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	log.Println("Listening for Telegram commands...")
+	for cmd := range tg.ListenForCommands(bot) {
+		switch cmd.Name {
+		case "chart":
+			handleChartCommand(bot, client, cmd.Args)
+		case "advise":
+			handleAdviseCommand(bot, client, cmd.Args)
+		default:
+			tg.SendMessage(bot, "Unknown command. Available: /chart FROM TO [days], /advise FROM TO YYYY-MM-DD [single|round|multi]")
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package calculation
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// madScale makes the median absolute deviation comparable to a standard
+// deviation for normally distributed prices.
+const madScale = 1.4826
+
+// minAnomalySamples is the history needed before anything is flagged.
+const minAnomalySamples = 10
+
+// Anomaly describes how far a price lies below the usual prices of its
+// departure month.
+type Anomaly struct {
+	Median  float64
+	MAD     float64
+	Score   float64 // scaled MADs below the median
+	Samples int
+}
+
+// DetectAnomaly compares a price with the history of the same departure
+// month and returns an Anomaly when it lies at least threshold scaled
+// MADs below the median. It returns nil for normal prices, for prices
+// above the median and when there is too little history.
+func DetectAnomaly(price float64, departure time.Time, history []Observation, threshold float64) *Anomaly {
+	var prices []float64
+	for _, o := range history {
+		if o.DepartureDate.Year() == departure.Year() && o.DepartureDate.Month() == departure.Month() {
+			prices = append(prices, o.Price)
+		}
+	}
+
+	if len(prices) < minAnomalySamples {
+		return nil
+	}
+
+	sort.Float64s(prices)
+	median := Percentile(prices, 50)
+
+	deviations := make([]float64, len(prices))
+	for i, p := range prices {
+		deviations[i] = math.Abs(p - median)
+	}
+	sort.Float64s(deviations)
+	mad := Percentile(deviations, 50)
+
+	// Perfectly flat history would flag every cent below the median.
+	spread := math.Max(mad*madScale, median*0.01)
+	score := (median - price) / spread
+
+	if score < threshold {
+		return nil
+	}
+
+	return &Anomaly{
+		Median:  median,
+		MAD:     mad,
+		Score:   score,
+		Samples: len(prices),
+	}
+}
@@ -0,0 +1,41 @@
+package calculation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectAnomaly(t *testing.T) {
+	june := time.Date(2023, 6, 10, 0, 0, 0, 0, time.UTC)
+	july := time.Date(2023, 7, 10, 0, 0, 0, 0, time.UTC)
+
+	var history []Observation
+	for i, p := range []float64{300, 310, 290, 305, 295, 320, 280, 300, 315, 285} {
+		history = append(history, Observation{DepartureDate: june.Add(time.Duration(i) * 24 * time.Hour), Price: p})
+	}
+	history = append(history, Observation{DepartureDate: july, Price: 90})
+
+	tests := []struct {
+		name      string
+		price     float64
+		departure time.Time
+		anomaly   bool
+	}{
+		{"normal price", 290, june, false},
+		{"expensive price", 500, june, false},
+		{"error fare", 120, june, true},
+		{"too little history", 120, july, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := DetectAnomaly(tt.price, tt.departure, history, 3.5)
+			if (a != nil) != tt.anomaly {
+				t.Fatalf("expected anomaly %t, got %v", tt.anomaly, a)
+			}
+			if a != nil && (a.Median != 300 || a.Samples != 10) {
+				t.Errorf("unexpected anomaly %+v", a)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package calculation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bucketLimits are the upper ends, in days to departure, of the buckets
+// observations are grouped into.
+var bucketLimits = []int{7, 14, 21, 30, 45, 60, 90, 120, math.MaxInt32}
+
+const (
+	BuyNow = "buy now"
+	Wait   = "wait"
+)
+
+// Observation is a price seen for a departure date on a given day, with
+// Kayak's advice at that moment if there was one.
+type Observation struct {
+	DepartureDate time.Time
+	ObservedOn    time.Time
+	Price         float64
+
+	Advice     string // model.AdviceBuy or model.AdviceWait
+	AdviceDays int
+}
+
+func (o *Observation) daysToDeparture() int {
+	return int(o.DepartureDate.Sub(o.ObservedOn).Hours() / 24)
+}
+
+// Bucket holds the median relative price of all observations made
+// between MinDays and MaxDays before departure. A relative price of 1.1
+// means 10% above the typical price of that departure date.
+type Bucket struct {
+	MinDays       int
+	MaxDays       int
+	Count         int
+	RelativePrice float64
+}
+
+func (b *Bucket) String() string {
+	days := fmt.Sprintf("%d-%d", b.MinDays, b.MaxDays)
+	if b.MaxDays == math.MaxInt32 {
+		days = fmt.Sprintf("%d+", b.MinDays)
+	}
+	return fmt.Sprintf("%8s days: %+6.1f%% (%d obs.)", days, (b.RelativePrice-1)*100, b.Count)
+}
+
+type BookingAdvice struct {
+	Recommendation string
+	// Confidence ranges from 0 to 1. It grows with the number of
+	// departure dates that followed the same pattern in the past.
+	Confidence float64
+	// ExpectedChange is the relative price change expected when waiting
+	// until WaitUntilDays before departure.
+	ExpectedChange float64
+	WaitUntilDays  int
+	Current        *Bucket
+	Buckets        []Bucket
+}
+
+// minimumChange is the price drop that makes waiting worth it.
+const minimumChange = 0.02
+
+// RecommendBooking estimates from past observations whether to buy now,
+// daysToDeparture days before departure, or to wait for a cheaper bucket.
+// It returns nil when no observations fall into the current bucket.
+func RecommendBooking(observations []Observation, daysToDeparture int) *BookingAdvice {
+	relative := relativePrices(observations)
+	buckets := groupBuckets(observations, relative)
+
+	var current *Bucket
+	for i := range buckets {
+		if daysToDeparture >= buckets[i].MinDays && daysToDeparture <= buckets[i].MaxDays {
+			current = &buckets[i]
+		}
+	}
+	if current == nil {
+		return nil
+	}
+
+	advice := &BookingAdvice{
+		Recommendation: BuyNow,
+		Current:        current,
+		Buckets:        buckets,
+	}
+
+	var target *Bucket
+	for i := range buckets {
+		b := &buckets[i]
+		if b.MaxDays >= current.MinDays {
+			continue
+		}
+		if target == nil || b.RelativePrice < target.RelativePrice {
+			target = b
+		}
+	}
+
+	if target != nil {
+		change := target.RelativePrice/current.RelativePrice - 1
+		if change < -minimumChange {
+			advice.Recommendation = Wait
+			advice.ExpectedChange = change
+			advice.WaitUntilDays = target.MaxDays
+		}
+	}
+
+	advice.Confidence = confidence(observations, current, target, advice.Recommendation)
+	return advice
+}
+
+// relativePrices divides every price by the median price of its
+// departure date, so routes with expensive and cheap dates can be mixed.
+func relativePrices(observations []Observation) []float64 {
+	byDate := make(map[time.Time][]float64)
+	for _, o := range observations {
+		byDate[o.DepartureDate] = append(byDate[o.DepartureDate], o.Price)
+	}
+
+	medians := make(map[time.Time]float64)
+	for d, prices := range byDate {
+		sort.Float64s(prices)
+		medians[d] = Percentile(prices, 50)
+	}
+
+	relative := make([]float64, len(observations))
+	for i, o := range observations {
+		relative[i] = o.Price / medians[o.DepartureDate]
+	}
+	return relative
+}
+
+func bucketIndex(days int) int {
+	for i, limit := range bucketLimits {
+		if days <= limit {
+			return i
+		}
+	}
+	return len(bucketLimits) - 1
+}
+
+func groupBuckets(observations []Observation, relative []float64) []Bucket {
+	values := make([][]float64, len(bucketLimits))
+	for i, o := range observations {
+		days := o.daysToDeparture()
+		if days < 0 {
+			continue
+		}
+		idx := bucketIndex(days)
+		values[idx] = append(values[idx], relative[i])
+	}
+
+	var buckets []Bucket
+	for i, v := range values {
+		if len(v) == 0 {
+			continue
+		}
+		sort.Float64s(v)
+
+		minDays := 0
+		if i > 0 {
+			minDays = bucketLimits[i-1] + 1
+		}
+		buckets = append(buckets, Bucket{
+			MinDays:       minDays,
+			MaxDays:       bucketLimits[i],
+			Count:         len(v),
+			RelativePrice: Percentile(v, 50),
+		})
+	}
+	return buckets
+}
+
+// confidence is the share of departure dates whose prices moved the way
+// the recommendation expects between the two buckets, scaled down when
+// only few departure dates could be compared.
+func confidence(observations []Observation, current *Bucket, target *Bucket, recommendation string) float64 {
+	if target == nil {
+		return sampleFactor(current.Count) * 0.5
+	}
+
+	type pair struct {
+		current, target []float64
+	}
+	byDate := make(map[time.Time]*pair)
+
+	for _, o := range observations {
+		days := o.daysToDeparture()
+		p, ok := byDate[o.DepartureDate]
+		if !ok {
+			p = &pair{}
+			byDate[o.DepartureDate] = p
+		}
+		if days >= current.MinDays && days <= current.MaxDays {
+			p.current = append(p.current, o.Price)
+		} else if days >= target.MinDays && days <= target.MaxDays {
+			p.target = append(p.target, o.Price)
+		}
+	}
+
+	compared, agreeing := 0, 0
+	for _, p := range byDate {
+		if len(p.current) == 0 || len(p.target) == 0 {
+			continue
+		}
+		compared++
+
+		dropped := mean(p.target) < mean(p.current)
+		if dropped == (recommendation == Wait) {
+			agreeing++
+		}
+	}
+
+	if compared == 0 {
+		return sampleFactor(current.Count) * 0.5
+	}
+	return float64(agreeing) / float64(compared) * sampleFactor(compared)
+}
+
+func sampleFactor(n int) float64 {
+	return math.Min(1, float64(n)/10)
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func (a *BookingAdvice) String() string {
+	lines := []string{}
+
+	if a.Recommendation == Wait {
+		lines = append(lines, fmt.Sprintf(
+			"Recommendation: wait until %d days before departure, prices tend to change by %+.1f%% (confidence %.0f%%)",
+			a.WaitUntilDays, a.ExpectedChange*100, a.Confidence*100,
+		))
+	} else {
+		lines = append(lines, fmt.Sprintf(
+			"Recommendation: buy now, prices don't tend to drop closer to departure (confidence %.0f%%)",
+			a.Confidence*100,
+		))
+	}
+
+	lines = append(lines, "Price relative to the typical price of a departure date, by days to departure:")
+	for _, b := range a.Buckets {
+		marker := " "
+		if b == *a.Current {
+			marker = ">"
+		}
+		lines = append(lines, marker+" "+b.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,69 @@
+package calculation
+
+import (
+	"testing"
+	"time"
+)
+
+// createObservations simulates departure dates whose price follows the
+// given price per days-to-departure curve.
+func createObservations(curve map[int]float64, dates int) []Observation {
+	start := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	var observations []Observation
+	for i := 0; i < dates; i++ {
+		departure := start.Add(time.Duration(i) * 24 * time.Hour)
+		for days, price := range curve {
+			observations = append(observations, Observation{
+				DepartureDate: departure,
+				ObservedOn:    departure.Add(-time.Duration(days) * 24 * time.Hour),
+				Price:         price + float64(i),
+			})
+		}
+	}
+	return observations
+}
+
+func TestRecommendBookingWait(t *testing.T) {
+	observations := createObservations(map[int]float64{100: 300, 50: 250, 25: 280, 5: 400}, 12)
+
+	advice := RecommendBooking(observations, 100)
+	if advice == nil {
+		t.Fatal("expected advice")
+	}
+	if advice.Recommendation != Wait {
+		t.Errorf("expected %s, got %s", Wait, advice.Recommendation)
+	}
+	if advice.WaitUntilDays != 60 {
+		t.Errorf("expected to wait until 60 days before departure, got %d", advice.WaitUntilDays)
+	}
+	if advice.ExpectedChange >= 0 {
+		t.Errorf("expected a price drop, got %.2f", advice.ExpectedChange)
+	}
+	if advice.Confidence != 1 {
+		t.Errorf("expected full confidence, got %.2f", advice.Confidence)
+	}
+}
+
+func TestRecommendBookingBuyNow(t *testing.T) {
+	observations := createObservations(map[int]float64{50: 250, 25: 280, 5: 400}, 5)
+
+	advice := RecommendBooking(observations, 50)
+	if advice == nil {
+		t.Fatal("expected advice")
+	}
+	if advice.Recommendation != BuyNow {
+		t.Errorf("expected %s, got %s", BuyNow, advice.Recommendation)
+	}
+	if advice.Confidence != 0.5 {
+		t.Errorf("expected confidence to be scaled by the 5 compared dates, got %.2f", advice.Confidence)
+	}
+}
+
+func TestRecommendBookingWithoutHistory(t *testing.T) {
+	observations := createObservations(map[int]float64{5: 400}, 3)
+
+	if advice := RecommendBooking(observations, 100); advice != nil {
+		t.Errorf("expected no advice, got %v", advice)
+	}
+}
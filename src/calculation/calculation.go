@@ -2,10 +2,16 @@ package calculation
 
 import "airliner/model"
 
+// GetMinPriceOffer returns the cheapest offer not excluded by its
+// payload's filter. Prices are compared as they are, so offers of
+// different currencies need to be converted first, see Converter.
 func GetMinPriceOffer(offers []*model.Offer) *model.Offer {
 	var min *model.Offer
 
 	for _, o := range offers {
+		if o.Excluded {
+			continue
+		}
 
 		if min == nil || o.Price < min.Price {
 			min = o
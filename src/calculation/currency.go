@@ -0,0 +1,190 @@
+package calculation
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"airliner/model"
+)
+
+// EcbUrl is the ECB's feed of the day's euro reference rates.
+const EcbUrl = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+// Rates holds the units of every currency per unit of a common base,
+// keyed by ISO 4217 code. The base itself has the rate 1.
+type Rates map[string]float64
+
+// LoadRatesFile reads a JSON object of rates like
+// {"EUR": 1, "USD": 1.0812, "GBP": 0.8571}.
+func LoadRatesFile(path string) (Rates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates Rates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("invalid rates file %s: %s", path, err)
+	}
+	return rates.normalize()
+}
+
+// ParseEcb reads the ECB's euro reference rates XML and returns the
+// rates, including EUR, and the day they were published.
+func ParseEcb(r io.Reader) (Rates, time.Time, error) {
+	var envelope struct {
+		Cube struct {
+			Cube struct {
+				Time  string `xml:"time,attr"`
+				Rates []struct {
+					Currency string  `xml:"currency,attr"`
+					Rate     float64 `xml:"rate,attr"`
+				} `xml:"Cube"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	}
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid ECB rates: %s", err)
+	}
+
+	day := envelope.Cube.Cube
+	published, err := time.Parse("2006-01-02", day.Time)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid ECB rates date '%s'", day.Time)
+	}
+
+	rates := Rates{"EUR": 1}
+	for _, r := range day.Rates {
+		rates[r.Currency] = r.Rate
+	}
+	rates, err = rates.normalize()
+	return rates, published, err
+}
+
+// LoadEcbRates reads the ECB rates cached at cachePath. When refresh is
+// positive and the cache is older than that, the rates are downloaded
+// from url first; if that fails, the stale cache is used.
+func LoadEcbRates(url string, cachePath string, refresh time.Duration) (Rates, error) {
+	info, err := os.Stat(cachePath)
+	stale := err != nil || time.Since(info.ModTime()) > refresh
+	if refresh > 0 && stale {
+		if err := downloadFile(url, cachePath); err != nil {
+			if info == nil {
+				return nil, err
+			}
+			slog.Warn("Couldn't refresh the exchange rates, using the cached ones", "err", err, "cachedOn", info.ModTime())
+		}
+	}
+
+	f, err := os.Open(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rates, _, err := ParseEcb(f)
+	return rates, err
+}
+
+func downloadFile(url string, path string) error {
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// only replace the cache with rates that can be read
+	if _, _, err := ParseEcb(bytes.NewReader(data)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (r Rates) normalize() (Rates, error) {
+	normalized := make(Rates, len(r))
+	for currency, rate := range r {
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid rate %g for %s", rate, currency)
+		}
+		normalized[strings.ToUpper(currency)] = rate
+	}
+	return normalized, nil
+}
+
+// Converter converts prices into the reporting currency To.
+type Converter struct {
+	Rates Rates
+	To    string
+}
+
+// NewConverter returns a converter into the reporting currency to.
+func NewConverter(rates Rates, to string) (*Converter, error) {
+	c := &Converter{Rates: rates, To: strings.ToUpper(to)}
+	if err := c.Supports(c.To); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Supports returns an error unless prices in currency can be converted.
+func (c *Converter) Supports(currency string) error {
+	if _, ok := c.Rates[strings.ToUpper(currency)]; !ok {
+		return fmt.Errorf("no exchange rate for %s", currency)
+	}
+	return nil
+}
+
+// Convert returns amount of currency in the reporting currency. An empty
+// currency is taken for the reporting currency.
+func (c *Converter) Convert(amount float64, currency string) (float64, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "" || currency == c.To {
+		return amount, nil
+	}
+
+	from, ok := c.Rates[currency]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", currency)
+	}
+	return amount / from * c.Rates[c.To], nil
+}
+
+// ConvertOffer moves the offer's price into the reporting currency and
+// keeps the original one in OriginalPrice and OriginalCurrency, so offers
+// of different markets compare correctly. Offers without a price are left
+// alone.
+func (c *Converter) ConvertOffer(o *model.Offer) error {
+	if !o.FetchSuccessful || o.Currency == "" || strings.EqualFold(o.Currency, c.To) {
+		return nil
+	}
+	if o.OriginalCurrency != "" {
+		return errors.New("offer was converted already")
+	}
+
+	converted, err := c.Convert(o.Price, o.Currency)
+	if err != nil {
+		return err
+	}
+
+	o.OriginalPrice, o.OriginalCurrency = o.Price, o.Currency
+	o.Price, o.Currency = converted, c.To
+	return nil
+}
@@ -0,0 +1,163 @@
+package calculation
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"airliner/model"
+)
+
+const ecbSample = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<gesmes:Sender>
+		<gesmes:name>European Central Bank</gesmes:name>
+	</gesmes:Sender>
+	<Cube>
+		<Cube time='2023-05-02'>
+			<Cube currency='USD' rate='1.1000'/>
+			<Cube currency='GBP' rate='0.8800'/>
+			<Cube currency='CHF' rate='0.9800'/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestParseEcb(t *testing.T) {
+	rates, published, err := ParseEcb(strings.NewReader(ecbSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !published.Equal(time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected publishing date %s", published)
+	}
+	if rates["EUR"] != 1 || rates["USD"] != 1.1 || rates["GBP"] != 0.88 {
+		t.Errorf("unexpected rates %v", rates)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	c, err := NewConverter(Rates{"EUR": 1, "USD": 1.1, "GBP": 0.88}, "eur")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		amount   float64
+		currency string
+		want     float64
+	}{
+		{110, "USD", 100},
+		{88, "GBP", 100},
+		{100, "EUR", 100},
+		{100, "", 100},
+	}
+
+	for _, tt := range tests {
+		got, err := c.Convert(tt.amount, tt.currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%.2f %s: expected %.2f, got %.2f", tt.amount, tt.currency, tt.want, got)
+		}
+	}
+
+	if _, err := c.Convert(100, "JPY"); err == nil {
+		t.Errorf("expected an error for a currency without a rate")
+	}
+	if _, err := NewConverter(Rates{"EUR": 1}, "USD"); err == nil {
+		t.Errorf("expected an error for a reporting currency without a rate")
+	}
+}
+
+func TestConvertOffer(t *testing.T) {
+	c, _ := NewConverter(Rates{"EUR": 1, "GBP": 0.8}, "EUR")
+
+	offers := []*model.Offer{
+		{Price: 240, Currency: "GBP", FetchSuccessful: true},
+		{Price: 290, Currency: "EUR", FetchSuccessful: true},
+		{Price: -1, Currency: "GBP", FetchSuccessful: false},
+	}
+	for _, o := range offers {
+		if err := c.ConvertOffer(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if o := offers[0]; o.Price != 300 || o.Currency != "EUR" || o.OriginalPrice != 240 || o.OriginalCurrency != "GBP" {
+		t.Errorf("unexpected converted offer %+v", o)
+	}
+	if o := offers[1]; o.Price != 290 || o.OriginalCurrency != "" {
+		t.Errorf("offer in the reporting currency shouldn't change, got %+v", o)
+	}
+	if o := offers[2]; o.Price != -1 || o.Currency != "GBP" {
+		t.Errorf("failed offer shouldn't change, got %+v", o)
+	}
+
+	// the cheapest offer in pounds isn't the cheapest one in euros
+	if best := GetMinPriceOffer(offers[:2]); best != offers[1] {
+		t.Errorf("expected the euro offer to be the cheapest, got %+v", best)
+	}
+}
+
+func TestLoadEcbRates(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(ecbSample))
+	}))
+	defer server.Close()
+
+	cache := filepath.Join(t.TempDir(), "ecb.xml")
+
+	if _, err := LoadEcbRates(server.URL, cache, 0); err == nil {
+		t.Errorf("expected an error without cache and refresh")
+	}
+
+	rates, err := LoadEcbRates(server.URL, cache, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates["USD"] != 1.1 {
+		t.Errorf("unexpected rates %v", rates)
+	}
+
+	if _, err := LoadEcbRates(server.URL, cache, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("expected the fresh cache to be used, got %d downloads", requests)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(cache, old, old)
+	server.Close()
+	if _, err := LoadEcbRates(server.URL, cache, time.Hour); err != nil {
+		t.Errorf("expected the stale cache to be used when the download fails, got %s", err)
+	}
+}
+
+func TestLoadRatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	os.WriteFile(path, []byte(`{"eur": 1, "USD": 1.08}`), 0o644)
+
+	rates, err := LoadRatesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates["EUR"] != 1 || rates["USD"] != 1.08 {
+		t.Errorf("unexpected rates %v", rates)
+	}
+
+	os.WriteFile(path, []byte(`{"EUR": 1, "USD": 0}`), 0o644)
+	if _, err := LoadRatesFile(path); err == nil {
+		t.Errorf("expected an error for a zero rate")
+	}
+}
@@ -0,0 +1,56 @@
+package calculation
+
+import (
+	"time"
+
+	"airliner/model"
+)
+
+// defaultAdviceDays is the horizon used for advice without one.
+const defaultAdviceDays = 7
+
+// PredictionAccuracy checks Kayak's past advice against what the prices
+// did afterwards. A "buy" was right when no later observation of the same
+// departure date within the advice's horizon was cheaper by more than
+// minimumChange, a "wait" when one was. Advice without a later observation
+// to compare with isn't counted.
+func PredictionAccuracy(observations []Observation) (correct int, total int) {
+	byDate := make(map[time.Time][]Observation)
+	for _, o := range observations {
+		byDate[o.DepartureDate] = append(byDate[o.DepartureDate], o)
+	}
+
+	for _, o := range observations {
+		if o.Advice != model.AdviceBuy && o.Advice != model.AdviceWait {
+			continue
+		}
+
+		days := o.AdviceDays
+		if days == 0 {
+			days = defaultAdviceDays
+		}
+		horizon := o.ObservedOn.Add(time.Duration(days) * model.Day)
+
+		compared, dropped := false, false
+		for _, later := range byDate[o.DepartureDate] {
+			if !later.ObservedOn.After(o.ObservedOn) || later.ObservedOn.After(horizon) {
+				continue
+			}
+			compared = true
+			if later.Price < o.Price*(1-minimumChange) {
+				dropped = true
+			}
+		}
+
+		if !compared {
+			continue
+		}
+
+		total++
+		if dropped == (o.Advice == model.AdviceWait) {
+			correct++
+		}
+	}
+
+	return correct, total
+}
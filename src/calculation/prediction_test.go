@@ -0,0 +1,30 @@
+package calculation
+
+import (
+	"testing"
+	"time"
+
+	"airliner/model"
+)
+
+func TestPredictionAccuracy(t *testing.T) {
+	departure := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	other := departure.Add(model.Day)
+	day := func(n int) time.Time { return departure.Add(-time.Duration(60-n) * model.Day) }
+
+	observations := []Observation{
+		// right: bought before a rise
+		{DepartureDate: departure, ObservedOn: day(0), Price: 200, Advice: model.AdviceBuy, AdviceDays: 7},
+		{DepartureDate: departure, ObservedOn: day(3), Price: 220, Advice: model.AdviceWait},
+		// wrong: waiting, but prices rose
+		{DepartureDate: departure, ObservedOn: day(5), Price: 240},
+		// not counted: the drop came after the horizon
+		{DepartureDate: other, ObservedOn: day(0), Price: 300, Advice: model.AdviceBuy, AdviceDays: 2},
+		{DepartureDate: other, ObservedOn: day(10), Price: 250},
+	}
+
+	correct, total := PredictionAccuracy(observations)
+	if correct != 1 || total != 2 {
+		t.Errorf("expected 1 of 2 correct, got %d of %d", correct, total)
+	}
+}
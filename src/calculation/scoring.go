@@ -0,0 +1,169 @@
+package calculation
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"airliner/model"
+)
+
+// Scoring weighs an offer's itinerary against its price. Every weight is
+// given in the offer's currency, so a score reads like an adjusted price;
+// lower scores are better. The zero value scores by price only.
+type Scoring struct {
+	HourCost float64 // per hour of travel time
+	StopCost float64 // per stop on any leg
+
+	// DepartureCost is added per hour the outbound departure lies outside
+	// PreferredDeparture.
+	PreferredDeparture model.TimeWindow
+	DepartureCost      float64
+
+	// AirlineBonus is subtracted when every leg is flown by one of the
+	// preferred airlines.
+	PreferredAirlines []string
+	AirlineBonus      float64
+}
+
+func (s *Scoring) Score(o *model.Offer) float64 {
+	score := o.Price
+
+	it := o.Itinerary
+	if it == nil || len(it.Legs) == 0 {
+		return score
+	}
+
+	score += it.Duration().Hours() * s.HourCost
+
+	for _, l := range it.Legs {
+		score += float64(l.Stops) * s.StopCost
+	}
+
+	score += departureDistance(&it.Legs[0], s.PreferredDeparture).Hours() * s.DepartureCost
+
+	if len(s.PreferredAirlines) > 0 && flownBy(it, s.PreferredAirlines) {
+		score -= s.AirlineBonus
+	}
+
+	return score
+}
+
+// departureDistance returns how far the leg departs outside the window.
+func departureDistance(l *model.Leg, w model.TimeWindow) time.Duration {
+	if w.IsZero() || l.Departure.IsZero() {
+		return 0
+	}
+
+	dep := time.Duration(l.Departure.Hour())*time.Hour + time.Duration(l.Departure.Minute())*time.Minute
+
+	switch {
+	case dep < w.From:
+		return w.From - dep
+	case dep > w.To:
+		return dep - w.To
+	}
+	return 0
+}
+
+func flownBy(it *model.Itinerary, airlines []string) bool {
+	for _, l := range it.Legs {
+		if len(l.Airlines) == 0 {
+			return false
+		}
+		for _, a := range l.Airlines {
+			if !containsFold(airlines, a) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, v string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBestOffer returns the offer with the lowest score, skipping offers
+// excluded by their payload's filter.
+func GetBestOffer(offers []*model.Offer, scoring *Scoring) *model.Offer {
+	var best *model.Offer
+	bestScore := math.Inf(1)
+
+	for _, o := range offers {
+		if o.Excluded {
+			continue
+		}
+
+		if score := scoring.Score(o); best == nil || score < bestScore {
+			best = o
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+// ParetoFront returns the offers no other offer beats on price, travel
+// time and stops at once, in their original order. Offers without an
+// itinerary only compete with each other, on price.
+func ParetoFront(offers []*model.Offer) []*model.Offer {
+	var front []*model.Offer
+
+	for _, o := range offers {
+		if o.Excluded {
+			continue
+		}
+
+		dominated := false
+		for _, other := range offers {
+			if other != o && !other.Excluded && dominates(other, o) {
+				dominated = true
+				break
+			}
+		}
+
+		if !dominated {
+			front = append(front, o)
+		}
+	}
+
+	return front
+}
+
+// dominates reports whether a is at least as good as b in every criterion
+// and strictly better in one. Offers without an itinerary have no travel
+// time or stops to compare, they only compete with each other on price.
+func dominates(a *model.Offer, b *model.Offer) bool {
+	if hasItinerary(a) != hasItinerary(b) {
+		return false
+	}
+	if !hasItinerary(a) {
+		return a.Price < b.Price
+	}
+
+	aDur, aStops := itineraryCriteria(a)
+	bDur, bStops := itineraryCriteria(b)
+
+	if a.Price > b.Price || aDur > bDur || aStops > bStops {
+		return false
+	}
+	return a.Price < b.Price || aDur < bDur || aStops < bStops
+}
+
+func hasItinerary(o *model.Offer) bool {
+	return o.Itinerary != nil && len(o.Itinerary.Legs) > 0
+}
+
+func itineraryCriteria(o *model.Offer) (time.Duration, int) {
+	stops := 0
+	for _, l := range o.Itinerary.Legs {
+		stops += l.Stops
+	}
+	return o.Itinerary.Duration(), stops
+}
@@ -0,0 +1,90 @@
+package calculation
+
+import (
+	"testing"
+	"time"
+
+	"airliner/model"
+)
+
+func createOffer(price float64, duration time.Duration, stops int, departure int, airline string) *model.Offer {
+	day := time.Date(2023, 3, 17, 0, 0, 0, 0, time.UTC)
+	return &model.Offer{
+		Price: price,
+		Itinerary: &model.Itinerary{Legs: []model.Leg{{
+			Departure: day.Add(time.Duration(departure) * time.Hour),
+			Duration:  duration,
+			Stops:     stops,
+			Airlines:  []string{airline},
+		}}},
+		FetchSuccessful: true,
+	}
+}
+
+func TestGetBestOffer(t *testing.T) {
+	cheapLong := createOffer(200, 26*time.Hour, 2, 6, "FR")
+	direct := createOffer(300, 3*time.Hour, 0, 18, "TP")
+	excluded := createOffer(100, 2*time.Hour, 0, 18, "TP")
+	excluded.Excluded = true
+
+	offers := []*model.Offer{cheapLong, direct, excluded}
+
+	tests := []struct {
+		name    string
+		scoring Scoring
+		want    *model.Offer
+	}{
+		{"price only", Scoring{}, cheapLong},
+		{"hour cost", Scoring{HourCost: 15}, direct},
+		{"stop cost", Scoring{StopCost: 60}, direct},
+		{"departure cost", Scoring{PreferredDeparture: model.TimeWindow{From: 17 * time.Hour, To: 23 * time.Hour}, DepartureCost: 10}, direct},
+		{"airline bonus", Scoring{PreferredAirlines: []string{"tp"}, AirlineBonus: 150}, direct},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetBestOffer(offers, &tt.scoring); got != tt.want {
+				t.Errorf("want offer with price %.2f, got %.2f", tt.want.Price, got.Price)
+			}
+		})
+	}
+}
+
+func TestParetoFront(t *testing.T) {
+	cheap := createOffer(200, 26*time.Hour, 2, 6, "FR")
+	fast := createOffer(300, 3*time.Hour, 0, 18, "TP")
+	balanced := createOffer(250, 8*time.Hour, 1, 9, "LH")
+	dominated := createOffer(320, 9*time.Hour, 1, 9, "LH")
+
+	front := ParetoFront([]*model.Offer{cheap, fast, balanced, dominated})
+
+	want := []*model.Offer{cheap, fast, balanced}
+	if len(front) != len(want) {
+		t.Fatalf("expected %d offers, got %d", len(want), len(front))
+	}
+	for i, o := range want {
+		if front[i] != o {
+			t.Errorf("offer %d should have price %.2f, got %.2f", i, o.Price, front[i].Price)
+		}
+	}
+}
+
+func TestParetoFrontWithoutItinerary(t *testing.T) {
+	fast := createOffer(300, 3*time.Hour, 0, 18, "TP")
+	unknown := &model.Offer{Price: 320, FetchSuccessful: true}
+	cheapUnknown := &model.Offer{Price: 280, FetchSuccessful: true}
+
+	// offers without an itinerary neither beat nor are beaten by the
+	// ones with an itinerary, but the cheaper one beats the other
+	front := ParetoFront([]*model.Offer{fast, unknown, cheapUnknown})
+
+	want := []*model.Offer{fast, cheapUnknown}
+	if len(front) != len(want) {
+		t.Fatalf("expected %d offers, got %d", len(want), len(front))
+	}
+	for i, o := range want {
+		if front[i] != o {
+			t.Errorf("offer %d should have price %.2f, got %.2f", i, o.Price, front[i].Price)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package calculation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"airliner/model"
+)
+
+// Summary describes the price distribution of a run's offers.
+type Summary struct {
+	Count  int
+	Min    float64
+	Max    float64
+	Mean   float64
+	Median float64
+	P10    float64
+	P25    float64
+	P75    float64
+	P90    float64
+	StdDev float64
+
+	// CheapestWeekday is the departure weekday with the lowest mean price.
+	CheapestWeekday time.Weekday
+	// BestGap is how much cheaper the best departure date is than the
+	// second best one. Zero when there is only one date.
+	BestGap float64
+}
+
+// Summarize computes the summary of the successful, not excluded offers.
+// It returns nil when there are none.
+func Summarize(offers []*model.Offer) *Summary {
+	var included []*model.Offer
+	for _, o := range offers {
+		if o.FetchSuccessful && !o.Excluded {
+			included = append(included, o)
+		}
+	}
+
+	if len(included) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(included, func(i, j int) bool {
+		return included[i].Price < included[j].Price
+	})
+
+	prices := make([]float64, len(included))
+	sum := 0.0
+	for i, o := range included {
+		prices[i] = o.Price
+		sum += o.Price
+	}
+	mean := sum / float64(len(prices))
+
+	variance := 0.0
+	for _, p := range prices {
+		variance += (p - mean) * (p - mean)
+	}
+	variance /= float64(len(prices))
+
+	return &Summary{
+		Count:           len(prices),
+		Min:             prices[0],
+		Max:             prices[len(prices)-1],
+		Mean:            mean,
+		Median:          Percentile(prices, 50),
+		P10:             Percentile(prices, 10),
+		P25:             Percentile(prices, 25),
+		P75:             Percentile(prices, 75),
+		P90:             Percentile(prices, 90),
+		StdDev:          math.Sqrt(variance),
+		CheapestWeekday: cheapestWeekday(included),
+		BestGap:         bestGap(included),
+	}
+}
+
+// Percentile returns the p-th percentile of sorted values, interpolating
+// linearly between the closest ranks.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func cheapestWeekday(offers []*model.Offer) time.Weekday {
+	sums := make(map[time.Weekday]float64)
+	counts := make(map[time.Weekday]int)
+
+	for _, o := range offers {
+		d := o.DepartureDate.Weekday()
+		sums[d] += o.Price
+		counts[d]++
+	}
+
+	best := offers[0].DepartureDate.Weekday()
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if counts[d] == 0 {
+			continue
+		}
+		if sums[d]/float64(counts[d]) < sums[best]/float64(counts[best]) {
+			best = d
+		}
+	}
+
+	return best
+}
+
+// bestGap expects offers sorted by price.
+func bestGap(offers []*model.Offer) float64 {
+	best := offers[0]
+	for _, o := range offers[1:] {
+		if !o.DepartureDate.Equal(best.DepartureDate) || !o.ReturnDate.Equal(best.ReturnDate) {
+			return o.Price - best.Price
+		}
+	}
+	return 0
+}
+
+func (s *Summary) String() string {
+	lines := []string{
+		fmt.Sprintf("Run summary over %d offers:", s.Count),
+		fmt.Sprintf("Min %.2f, Max %.2f, Mean %.2f, Median %.2f, Std. dev. %.2f", s.Min, s.Max, s.Mean, s.Median, s.StdDev),
+		fmt.Sprintf("P10 %.2f, P25 %.2f, P75 %.2f, P90 %.2f", s.P10, s.P25, s.P75, s.P90),
+		fmt.Sprintf("Cheapest departure weekday: %s", s.CheapestWeekday),
+		fmt.Sprintf("Best date is %.2f cheaper than the second best", s.BestGap),
+	}
+	return strings.Join(lines, "\n")
+}
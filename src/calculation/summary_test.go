@@ -0,0 +1,59 @@
+package calculation
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"airliner/model"
+)
+
+func TestSummarize(t *testing.T) {
+	monday := time.Date(2023, 3, 13, 0, 0, 0, 0, time.UTC)
+
+	offers := []*model.Offer{}
+	for i, price := range []float64{300, 250, 400, 200, 350} {
+		offers = append(offers, &model.Offer{
+			DepartureDate:   monday.Add(time.Duration(i) * model.Day),
+			Price:           price,
+			FetchSuccessful: true,
+		})
+	}
+	offers = append(offers, &model.Offer{Price: -1, FetchSuccessful: false})
+	offers = append(offers, &model.Offer{Price: 10, FetchSuccessful: true, Excluded: true})
+
+	s := Summarize(offers)
+
+	expected := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"Min", s.Min, 200},
+		{"Max", s.Max, 400},
+		{"Mean", s.Mean, 300},
+		{"Median", s.Median, 300},
+		{"P25", s.P25, 250},
+		{"P90", s.P90, 380},
+		{"StdDev", s.StdDev, math.Sqrt(5000)},
+		{"BestGap", s.BestGap, 50},
+	}
+
+	if s.Count != 5 {
+		t.Errorf("Count should be 5 but got %d", s.Count)
+	}
+	for _, e := range expected {
+		if math.Abs(e.got-e.want) > 1e-9 {
+			t.Errorf("%s should be %.2f but got %.2f", e.name, e.want, e.got)
+		}
+	}
+	if s.CheapestWeekday != time.Thursday {
+		t.Errorf("CheapestWeekday should be Thursday but got %s", s.CheapestWeekday)
+	}
+}
+
+func TestSummarizeWithoutOffers(t *testing.T) {
+	if s := Summarize([]*model.Offer{{Price: -1}}); s != nil {
+		t.Errorf("expected no summary, got %v", s)
+	}
+}
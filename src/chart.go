@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	db "airliner/database"
+	md "airliner/model"
+	"airliner/notify"
+	"airliner/render"
+	tg "airliner/telegram"
+)
+
+// runChart implements "airliner chart": it plots the stored price history
+// of a route, one line per departure date.
+func runChart(args []string) {
+	fs := flag.NewFlagSet("chart", flag.ExitOnError)
+	var fromcity = fs.String("from", "", "3 letter upercase code for the city flying from.")
+	var tocity = fs.String("to", "", "3 letter upercase code for the city flying to.")
+	var days = fs.Int("days", 30, "number of days of history to plot")
+	var format = fs.String("format", "png", "output format, png or svg")
+	var out = fs.String("out", "", "output file (default chart.<format>)")
+	fs.Parse(args)
+
+	if *fromcity == "" || *tocity == "" {
+		fmt.Println("ERROR arguments --from and --to are required")
+		return
+	}
+	if *format != "png" && *format != "svg" {
+		fmt.Printf("ERROR unknown format '%s'\n", *format)
+		return
+	}
+	if *out == "" {
+		*out = "chart." + *format
+	}
+
+	client := db.InitDB("test_influxdb.env")
+	defer client.Close()
+
+	title, series, err := loadPriceHistory(client, strings.ToUpper(*fromcity), strings.ToUpper(*tocity), *days)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	if *format == "svg" {
+		err = render.WriteTrendSVG(f, title, series)
+	} else {
+		var img []byte
+		img, err = render.EncodePNG(render.TrendChart(title, series))
+		if err == nil {
+			_, err = f.Write(img)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Wrote chart to %s\n", *out)
+}
+
+func handleChartCommand(bot *tg.Bot, client db.DBClient, args []string) {
+	if len(args) < 2 {
+		tg.SendMessage(bot, "Usage: /chart FROM TO [days]")
+		return
+	}
+
+	days := 30
+	if len(args) > 2 {
+		v, err := strconv.Atoi(args[2])
+		if err != nil || v <= 0 {
+			tg.SendMessage(bot, fmt.Sprintf("Invalid number of days '%s'", args[2]))
+			return
+		}
+		days = v
+	}
+
+	title, series, err := loadPriceHistory(client, strings.ToUpper(args[0]), strings.ToUpper(args[1]), days)
+	if err != nil {
+		notifyError(&notify.Telegram{Bot: bot}, err.Error())
+		return
+	}
+	if len(series) == 0 {
+		tg.SendMessage(bot, "No stored offers for this route yet.")
+		return
+	}
+
+	img, err := render.EncodePNG(render.TrendChart(title, series))
+	if err != nil {
+		notifyError(&notify.Telegram{Bot: bot}, err.Error())
+		return
+	}
+	tg.SendImage(bot, "chart.png", bytes.NewReader(img))
+}
+
+// loadPriceHistory reads the route's offers and groups them into series
+// with priceSeries.
+func loadPriceHistory(client db.DBClient, from string, to string, days int) (string, []render.Series, error) {
+	offers, err := db.Read_offer_history(client, db.HistoryQuery{
+		FromAirport: from,
+		ToAirport:   to,
+		Since:       time.Now().Add(-time.Duration(days) * 24 * time.Hour),
+	}, db.Bucket)
+	if err != nil {
+		return "", nil, err
+	}
+
+	title := fmt.Sprintf("Price history %s-%s, last %d days", from, to, days)
+	return title, priceSeries(offers), nil
+}
+
+// priceSeries groups the offers into one series per departure date, or
+// per departure and return date pair for round trips. Multi-city offers
+// and every currency get series of their own, so their prices aren't
+// joined into one line.
+func priceSeries(offers []db.AirlineOffer) []render.Series {
+	byLabel := make(map[string]*render.Series)
+	for _, o := range offers {
+		label := o.DepartureDate.Format("2006-01-02")
+		if !o.ReturnDate.IsZero() {
+			label += "/" + o.ReturnDate.Format("01-02")
+		}
+		if o.TripMode == md.MultiCity {
+			label += " multi"
+		}
+		if o.Currency != "" {
+			label += " " + o.Currency
+		}
+
+		s, ok := byLabel[label]
+		if !ok {
+			s = &render.Series{Label: label}
+			byLabel[label] = s
+		}
+		s.Points = append(s.Points, render.Point{Time: o.CreatedOn, Price: o.Price})
+	}
+
+	series := make([]render.Series, 0, len(byLabel))
+	for _, s := range byLabel {
+		series = append(series, *s)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Label < series[j].Label })
+	return series
+}
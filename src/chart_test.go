@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	db "airliner/database"
+	md "airliner/model"
+)
+
+func TestPriceSeries(t *testing.T) {
+	departure := time.Date(2023, 6, 5, 0, 0, 0, 0, time.UTC)
+	run := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	offers := []db.AirlineOffer{
+		{DepartureDate: departure, Price: 150, Currency: "EUR", TripMode: md.SingleTrip, CreatedOn: run},
+		{DepartureDate: departure, Price: 140, Currency: "EUR", TripMode: md.SingleTrip, CreatedOn: run.Add(md.Day)},
+		{DepartureDate: departure, Price: 170, Currency: "USD", TripMode: md.SingleTrip, CreatedOn: run},
+		{DepartureDate: departure, Price: 320, Currency: "EUR", TripMode: md.MultiCity, CreatedOn: run},
+		{DepartureDate: departure, ReturnDate: departure.Add(7 * md.Day), Price: 260, Currency: "EUR", TripMode: md.RoundTrip, CreatedOn: run},
+	}
+
+	series := priceSeries(offers)
+	want := []struct {
+		label  string
+		points int
+	}{
+		{"2023-06-05 EUR", 2},
+		{"2023-06-05 USD", 1},
+		{"2023-06-05 multi EUR", 1},
+		{"2023-06-05/06-12 EUR", 1},
+	}
+	if len(series) != len(want) {
+		t.Fatalf("expected %d series, got %+v", len(want), series)
+	}
+	for i, w := range want {
+		if series[i].Label != w.label || len(series[i].Points) != w.points {
+			t.Errorf("expected series %s with %d points, got %s with %d", w.label, w.points, series[i].Label, len(series[i].Points))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	calc "airliner/calculation"
+)
+
+// currencyFlags select the reporting currency of a deployment and where
+// its exchange rates come from.
+type currencyFlags struct {
+	currency   *string
+	rates      *string
+	ecbcache   *string
+	ecbrefresh *time.Duration
+}
+
+func addCurrencyFlags(fs *flag.FlagSet) *currencyFlags {
+	return &currencyFlags{
+		currency:   fs.String("currency", "", "reporting currency to convert prices into, e.g. EUR, empty keeps the market's currency"),
+		rates:      fs.String("rates", "", "JSON file with exchange rates like {\"EUR\": 1, \"USD\": 1.08}, instead of the ECB's"),
+		ecbcache:   fs.String("ecb-cache", "airliner-ecb-rates.xml", "file caching the ECB's euro reference rates"),
+		ecbrefresh: fs.Duration("ecb-refresh", 0, "age after which the ECB rates are downloaded again, 0 only uses --ecb-cache"),
+	}
+}
+
+// load returns the converter into the reporting currency, or nil when
+// prices are kept in the market's currency.
+func (f *currencyFlags) load() (*calc.Converter, error) {
+	if *f.currency == "" {
+		return nil, nil
+	}
+
+	var rates calc.Rates
+	var err error
+	if *f.rates != "" {
+		rates, err = calc.LoadRatesFile(*f.rates)
+	} else {
+		rates, err = calc.LoadEcbRates(calc.EcbUrl, *f.ecbcache, *f.ecbrefresh)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return calc.NewConverter(rates, *f.currency)
+}
+
+// useConverter makes the search convert its prices with c, if not nil.
+// The market's currency has to have a rate.
+func useConverter(s *search, c *calc.Converter) error {
+	if c != nil {
+		if err := c.Supports(s.kayak.Market.Currency); err != nil {
+			return fmt.Errorf("can't report %s prices in %s: %s", s.kayak.Market.Name(), c.To, err)
+		}
+	}
+	s.converter = c
+	return nil
+}
@@ -3,13 +3,19 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
 	"github.com/joho/godotenv"
+
+	"airliner/metrics"
 )
 
 const Bucket = "airliner"
@@ -25,6 +31,62 @@ type AirlineOffer struct {
 	ReturnDate    time.Time
 	Price         float64
 	CreatedOn     time.Time
+	Legs          []AirlineOfferLeg
+
+	// Market is the site of the offer, e.g. "kayak.de", and Currency
+	// the ISO 4217 code of its price. Both are empty for old offers.
+	Market   string
+	Currency string
+	// TripMode is read back from the tripMode tag, it follows from the
+	// dates and legs when writing.
+	TripMode string
+	// OriginalPrice and OriginalCurrency are set when Price was converted
+	// into the reporting currency.
+	OriginalPrice    float64
+	OriginalCurrency string
+
+	// Kayak's price prediction at the time of the offer, empty if unknown.
+	AdviceText           string
+	AdviceRecommendation string
+	AdviceTrend          string
+	AdviceConfidence     int
+	AdviceWithinDays     int
+
+	Anomaly bool
+
+	// Attempts counts the fetches of the offer, ReadyRetries the repeated
+	// page polls of the last one.
+	Attempts     int
+	ReadyRetries int
+}
+
+// AirlineOfferLeg is one flight of a multi-city offer. Legs are stored
+// as separate points sharing the offer's url and timestamp.
+type AirlineOfferLeg struct {
+	FromAirport   string
+	ToAirport     string
+	DepartureDate time.Time
+}
+
+// RunSummary holds the price statistics of a single run.
+type RunSummary struct {
+	FromAirport     string
+	ToAirport       string
+	TripMode        string
+	Currency        string
+	Count           int
+	Min             float64
+	Max             float64
+	Mean            float64
+	Median          float64
+	P10             float64
+	P25             float64
+	P75             float64
+	P90             float64
+	StdDev          float64
+	CheapestWeekday string
+	BestGap         float64
+	CreatedOn       time.Time
 }
 
 type DBClient = influxdb2.Client
@@ -150,31 +212,31 @@ func init_testDB(t *testing.T) DBClient {
 func InitDB(envPath string) influxdb2.Client {
 	err := godotenv.Load(envPath) //load environement variable
 	if err != nil {
-		log.Println(err)
-		log.Println("Couldn't load ENVARS")
+		slog.Warn("Couldn't load ENVARS", "path", envPath, "err", err)
 	}
 
 	client, err := ConnectToInfluxDB() // create the client
 
 	if err != nil {
-		log.Println(err)
-		log.Fatal("impossible to connect to DB")
+		slog.Error("Impossible to connect to DB", "err", err)
+		os.Exit(1)
 	}
 
 	ctx := context.Background()
 	bucketsAPI := client.BucketsAPI()
 	existingBucket, err := bucketsAPI.FindBucketByName(ctx, Bucket)
 	if existingBucket == nil {
-		log.Println("Didn't find an existing bucket. Creating a new one.")
+		slog.Info("Didn't find an existing bucket, creating a new one", "bucket", Bucket)
 		// create new empty Bucket
 		dOrg, _ := client.OrganizationsAPI().FindOrganizationByName(ctx, org)
 		_, err = client.BucketsAPI().CreateBucketWithNameWithID(ctx, *dOrg.Id, Bucket)
 
 		if err != nil {
-			log.Fatal("impossible to new create Bucket")
+			slog.Error("Impossible to create the bucket", "bucket", Bucket, "err", err)
+			os.Exit(1)
 		}
 	} else {
-		log.Printf("Will use existing bucket with ID: %s \n", *existingBucket.Id)
+		slog.Info("Using existing bucket", "bucket", Bucket, "id", *existingBucket.Id)
 	}
 
 	return client
@@ -192,13 +254,32 @@ func write_event_with_line_protocol(client influxdb2.Client, t AirlineOffer, dbB
 	writeAPI.Flush()
 }
 
+var watchedWriteAPIs sync.Map
+
+// watchedWriteAPI returns the client's non-blocking write API for the
+// bucket, logging and counting the errors of its asynchronous writes.
+func watchedWriteAPI(client influxdb2.Client, dbBucket string) api.WriteAPI {
+	writeAPI := client.WriteAPI(org, dbBucket)
+
+	if _, watched := watchedWriteAPIs.LoadOrStore(writeAPI, true); !watched {
+		errs := writeAPI.Errors()
+		go func() {
+			for err := range errs {
+				slog.Error("Couldn't write to DB", "bucket", dbBucket, "err", err)
+				metrics.DBWriteErrors.Inc()
+			}
+		}()
+	}
+
+	return writeAPI
+}
+
 func Write_event_with_fluent_Style(client influxdb2.Client, t AirlineOffer, dbBucket string) {
-	log.Println("Writing offer to DB.")
+	slog.Info("Writing offer to DB", "route", t.FromAirport+"-"+t.ToAirport, "departureDate", t.DepartureDate.Format("2006-01-02"), "price", t.Price)
 	// Use blocking write client for writes to desired Bucket
-	writeAPI := client.WriteAPI(org, dbBucket)
+	writeAPI := watchedWriteAPI(client, dbBucket)
 	// create point using fluent style
 	p := influxdb2.NewPointWithMeasurement("airlineOffer").
-		AddTag("unit", "euro").
 		AddField("url", t.Url).
 		AddTag("fromAirport", t.FromAirport).
 		AddTag("toAirport", t.ToAirport).
@@ -206,17 +287,113 @@ func Write_event_with_fluent_Style(client influxdb2.Client, t AirlineOffer, dbBu
 		AddField("price", t.Price).
 		SetTime(t.CreatedOn)
 
-	if t.ReturnDate.IsZero() {
+	if len(t.Legs) > 0 {
+		p.AddTag("tripMode", "multi").AddTag("returnDate", t.ReturnDate.Format("2006-01-02"))
+	} else if t.ReturnDate.IsZero() {
 		p.AddTag("tripMode", "single")
 	} else {
 		p.AddTag("tripMode", "round").AddTag("returnDate", t.ReturnDate.Format("2006-01-02"))
 	}
 
+	if t.Anomaly {
+		p.AddTag("anomaly", "true")
+	}
+
+	if t.Market != "" {
+		p.AddTag("market", t.Market)
+	}
+	if t.Currency != "" {
+		p.AddTag("currency", t.Currency)
+	}
+
+	if t.OriginalCurrency != "" {
+		p.AddTag("originalCurrency", t.OriginalCurrency).AddField("originalPrice", t.OriginalPrice)
+	}
+
+	if t.Attempts > 0 {
+		p.AddField("attempts", t.Attempts).AddField("readyRetries", t.ReadyRetries)
+	}
+
+	if t.AdviceText != "" {
+		p.AddField("adviceText", t.AdviceText).
+			AddField("adviceRecommendation", t.AdviceRecommendation).
+			AddField("adviceTrend", t.AdviceTrend).
+			AddField("adviceConfidence", t.AdviceConfidence).
+			AddField("adviceWithinDays", t.AdviceWithinDays)
+	}
+
 	writeAPI.WritePoint(p)
+
+	for i, l := range t.Legs {
+		writeAPI.WritePoint(
+			influxdb2.NewPointWithMeasurement("airlineOfferLeg").
+				AddTag("leg", strconv.Itoa(i)).
+				AddTag("fromAirport", l.FromAirport).
+				AddTag("toAirport", l.ToAirport).
+				AddTag("departureDate", l.DepartureDate.Format("2006-01-02")).
+				AddField("url", t.Url).
+				AddField("price", t.Price).
+				SetTime(t.CreatedOn),
+		)
+	}
 	// Flush writes
 	writeAPI.Flush()
 }
 
+// Write_failed_offer stores a search that couldn't be fetched, so digests
+// can report failures after the run's debug data is gone.
+func Write_failed_offer(client influxdb2.Client, t AirlineOffer, dbBucket string) {
+	slog.Info("Writing failed offer to DB", "route", t.FromAirport+"-"+t.ToAirport, "departureDate", t.DepartureDate.Format("2006-01-02"))
+	writeAPI := watchedWriteAPI(client, dbBucket)
+	p := influxdb2.NewPointWithMeasurement("failedOffer").
+		AddField("url", t.Url).
+		AddTag("fromAirport", t.FromAirport).
+		AddTag("toAirport", t.ToAirport).
+		AddTag("departureDate", t.DepartureDate.Format("2006-01-02")).
+		SetTime(t.CreatedOn)
+
+	if !t.ReturnDate.IsZero() {
+		p.AddTag("returnDate", t.ReturnDate.Format("2006-01-02"))
+	}
+
+	if t.Market != "" {
+		p.AddTag("market", t.Market)
+	}
+
+	if t.Attempts > 0 {
+		p.AddField("attempts", t.Attempts).AddField("readyRetries", t.ReadyRetries)
+	}
+
+	writeAPI.WritePoint(p)
+	writeAPI.Flush()
+}
+
+func Write_run_summary(client influxdb2.Client, s RunSummary, dbBucket string) {
+	slog.Info("Writing run summary to DB", "route", s.FromAirport+"-"+s.ToAirport, "tripMode", s.TripMode, "count", s.Count)
+	writeAPI := watchedWriteAPI(client, dbBucket)
+	p := influxdb2.NewPointWithMeasurement("runSummary").
+		AddTag("fromAirport", s.FromAirport).
+		AddTag("toAirport", s.ToAirport).
+		AddTag("tripMode", s.TripMode).
+		AddTag("currency", s.Currency).
+		AddField("count", s.Count).
+		AddField("min", s.Min).
+		AddField("max", s.Max).
+		AddField("mean", s.Mean).
+		AddField("median", s.Median).
+		AddField("p10", s.P10).
+		AddField("p25", s.P25).
+		AddField("p75", s.P75).
+		AddField("p90", s.P90).
+		AddField("stddev", s.StdDev).
+		AddField("cheapestWeekday", s.CheapestWeekday).
+		AddField("bestGap", s.BestGap).
+		SetTime(s.CreatedOn)
+
+	writeAPI.WritePoint(p)
+	writeAPI.Flush()
+}
+
 func write_event_with_params_constror(client influxdb2.Client, t AirlineOffer, dbBucket string) {
 	// Use blocking write client for writes to desired Bucket
 	writeAPI := client.WriteAPI(org, dbBucket)
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+
+	md "airliner/model"
+)
+
+// HistoryQuery selects stored offers. Empty fields match any value.
+type HistoryQuery struct {
+	FromAirport string
+	ToAirport   string
+	// TripMode is one of md.SingleTrip, md.RoundTrip and md.MultiCity.
+	// Failed searches aren't stored with their trip mode.
+	TripMode string
+	// Currency, an ISO 4217 code, leaves out prices of other currencies.
+	Currency string
+	Since    time.Time
+}
+
+var codeRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Validate checks the fields of the query, which end up in a Flux query.
+func (q *HistoryQuery) Validate() error {
+	for _, a := range []string{q.FromAirport, q.ToAirport} {
+		if a != "" && !codeRegex.MatchString(a) {
+			return fmt.Errorf("invalid airport code '%s'", a)
+		}
+	}
+	if q.Currency != "" && !codeRegex.MatchString(q.Currency) {
+		return fmt.Errorf("invalid currency '%s'", q.Currency)
+	}
+	switch q.TripMode {
+	case "", md.SingleTrip, md.RoundTrip, md.MultiCity:
+	default:
+		return fmt.Errorf("invalid trip mode '%s'", q.TripMode)
+	}
+	return nil
+}
+
+// fluxString quotes s as a Flux string literal.
+func fluxString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func (q *HistoryQuery) flux(dbBucket string, measurement string) string {
+	filters := []string{fmt.Sprintf(`r["_measurement"] == %s`, fluxString(measurement))}
+	if q.FromAirport != "" {
+		filters = append(filters, fmt.Sprintf(`r["fromAirport"] == %s`, fluxString(q.FromAirport)))
+	}
+	if q.ToAirport != "" {
+		filters = append(filters, fmt.Sprintf(`r["toAirport"] == %s`, fluxString(q.ToAirport)))
+	}
+	if q.TripMode != "" {
+		filters = append(filters, fmt.Sprintf(`r["tripMode"] == %s`, fluxString(q.TripMode)))
+	}
+	if q.Currency != "" {
+		filters = append(filters, fmt.Sprintf(`r["currency"] == %s`, fluxString(q.Currency)))
+	}
+
+	return fmt.Sprintf(`from(bucket: %s)
+|> range(start: %s)
+|> filter(fn: (r) => %s)
+|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+|> group()
+|> sort(columns: ["_time"])`, fluxString(dbBucket), q.Since.UTC().Format(time.RFC3339), strings.Join(filters, " and "))
+}
+
+// Read_offer_history returns the stored offers matching the query, oldest
+// first.
+func Read_offer_history(client influxdb2.Client, q HistoryQuery, dbBucket string) ([]AirlineOffer, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return read_offers(client, q.flux(dbBucket, "airlineOffer"))
+}
+
+// Read_failed_offers returns the stored failed searches matching the
+// query, oldest first. Only the route, dates and url are set.
+func Read_failed_offers(client influxdb2.Client, q HistoryQuery, dbBucket string) ([]AirlineOffer, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return read_offers(client, q.flux(dbBucket, "failedOffer"))
+}
+
+func read_offers(client influxdb2.Client, fluxQuery string) ([]AirlineOffer, error) {
+	result, err := client.QueryAPI(org).Query(context.Background(), fluxQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	offers := []AirlineOffer{}
+	for result.Next() {
+		values := result.Record().Values()
+
+		o := AirlineOffer{
+			FromAirport: stringValue(values, "fromAirport"),
+			ToAirport:   stringValue(values, "toAirport"),
+			CreatedOn:   result.Record().Time(),
+		}
+		o.Url = stringValue(values, "url")
+		o.Market = stringValue(values, "market")
+		o.Currency = stringValue(values, "currency")
+		o.TripMode = stringValue(values, "tripMode")
+		if v, ok := values["price"].(float64); ok {
+			o.Price = v
+		}
+		o.OriginalCurrency = stringValue(values, "originalCurrency")
+		if v, ok := values["originalPrice"].(float64); ok {
+			o.OriginalPrice = v
+		}
+		o.AdviceText = stringValue(values, "adviceText")
+		o.AdviceRecommendation = stringValue(values, "adviceRecommendation")
+		o.AdviceTrend = stringValue(values, "adviceTrend")
+		o.AdviceConfidence = intValue(values, "adviceConfidence")
+		o.AdviceWithinDays = intValue(values, "adviceWithinDays")
+		o.Attempts = intValue(values, "attempts")
+		o.ReadyRetries = intValue(values, "readyRetries")
+		o.DepartureDate, _ = time.Parse("2006-01-02", stringValue(values, "departureDate"))
+		o.ReturnDate, _ = time.Parse("2006-01-02", stringValue(values, "returnDate"))
+
+		offers = append(offers, o)
+	}
+
+	return offers, result.Err()
+}
+
+func stringValue(values map[string]interface{}, key string) string {
+	if v, ok := values[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func intValue(values map[string]interface{}, key string) int {
+	if v, ok := values[key].(int64); ok {
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryQueryValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		query HistoryQuery
+		valid bool
+	}{
+		{"any route", HistoryQuery{}, true},
+		{"route", HistoryQuery{FromAirport: "LIS", ToAirport: "MUC"}, true},
+		{"lowercase", HistoryQuery{FromAirport: "lis"}, false},
+		{"injection", HistoryQuery{ToAirport: `MUC" or true or "`}, false},
+		{"trip mode and currency", HistoryQuery{TripMode: "single", Currency: "EUR"}, true},
+		{"unknown trip mode", HistoryQuery{TripMode: "oneway"}, false},
+		{"invalid currency", HistoryQuery{Currency: "€"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.query.Validate(); (err == nil) != tt.valid {
+				t.Errorf("expected valid %t, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestHistoryQueryFlux(t *testing.T) {
+	q := HistoryQuery{FromAirport: "LIS", Since: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)}
+
+	flux := q.flux(`air"liner`, "airlineOffer")
+	for _, want := range []string{`from(bucket: "air\"liner")`, `r["fromAirport"] == "LIS"`, "range(start: 2023-05-01T00:00:00Z)"} {
+		if !strings.Contains(flux, want) {
+			t.Errorf("expected %q in %s", want, flux)
+		}
+	}
+	q.TripMode, q.Currency = "round", "EUR"
+	flux = q.flux("airliner", "airlineOffer")
+	for _, want := range []string{`r["tripMode"] == "round"`, `r["currency"] == "EUR"`} {
+		if !strings.Contains(flux, want) {
+			t.Errorf("expected %q in %s", want, flux)
+		}
+	}
+
+	if strings.Contains(flux, "toAirport") {
+		t.Errorf("expected no filter on toAirport in %s", flux)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	db "airliner/database"
+	"airliner/digest"
+	"airliner/notify"
+	tg "airliner/telegram"
+)
+
+// runDigest implements "airliner digest": it reports the best price per
+// route of the last day or week, compared to the period before.
+func runDigest(args []string) {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	var period = fs.String("period", "daily", "period to report, daily or weekly")
+	var format = fs.String("format", "telegram", "where to send the report: telegram, email or markdown")
+	var out = fs.String("out", "digest.md", "output file of the markdown format")
+	var history = fs.Int("history", 365, "days of history to look for new lows in")
+	fs.Parse(args)
+
+	var length time.Duration
+	switch *period {
+	case "daily":
+		length = 24 * time.Hour
+	case "weekly":
+		length = 7 * 24 * time.Hour
+	default:
+		fmt.Printf("ERROR unknown period '%s'\n", *period)
+		return
+	}
+	if *format != "telegram" && *format != "email" && *format != "markdown" {
+		fmt.Printf("ERROR unknown format '%s'\n", *format)
+		return
+	}
+
+	client := db.InitDB("test_influxdb.env")
+	defer client.Close()
+	godotenv.Load()
+
+	end := time.Now()
+	start := end.Add(-length)
+
+	offers, err := db.Read_offer_history(client, db.HistoryQuery{
+		Since: end.Add(-time.Duration(*history) * 24 * time.Hour),
+	}, db.Bucket)
+	if err != nil {
+		log.Fatal(err)
+	}
+	failed, err := db.Read_failed_offers(client, db.HistoryQuery{Since: start}, db.Bucket)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	title := "Airliner daily digest"
+	if *period == "weekly" {
+		title = "Airliner weekly digest"
+	}
+	d := digest.Build(title, start, end, offers, failed)
+
+	switch *format {
+	case "telegram":
+		bot, err := tg.InitBot()
+		if err != nil {
+			log.Panic(err)
+		}
+		tg.SendHTMLMessage(bot, digest.TelegramHTML(d))
+	case "email":
+		email, err := notify.InitEmail()
+		if err != nil {
+			log.Fatal(err)
+		}
+		body, err := digest.EmailHTML(d)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := email.SendHTML(title, body); err != nil {
+			log.Fatal(err)
+		}
+	case "markdown":
+		if err := os.WriteFile(*out, []byte(digest.Markdown(d)), 0644); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Wrote digest to %s\n", *out)
+	}
+}
@@ -0,0 +1,151 @@
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	db "airliner/database"
+	md "airliner/model"
+)
+
+// Route sums up the offers of one route, trip mode and currency within the
+// digest period.
+type Route struct {
+	FromAirport   string
+	ToAirport     string
+	TripMode      string
+	Currency      string
+	Best          float64
+	DepartureDate time.Time
+	ReturnDate    time.Time
+	Url           string
+	Offers        int
+
+	// PreviousBest is the best price of the period before, zero if the
+	// route wasn't searched then.
+	PreviousBest float64
+	// NewLow is set when Best is lower than any price stored before the
+	// period.
+	NewLow bool
+}
+
+// Name returns the route, e.g. "LIS-MUC" for round trips or "LIS-MUC
+// one-way".
+func (r *Route) Name() string {
+	name := r.FromAirport + "-" + r.ToAirport
+	switch r.TripMode {
+	case md.SingleTrip:
+		name += " one-way"
+	case md.MultiCity:
+		name += " multi-city"
+	}
+	return name
+}
+
+// BestPrice returns Best with its currency, if known.
+func (r *Route) BestPrice() string {
+	if r.Currency == "" {
+		return fmt.Sprintf("%.2f", r.Best)
+	}
+	return fmt.Sprintf("%.2f %s", r.Best, r.Currency)
+}
+
+// Change returns the price difference to the previous period.
+func (r *Route) Change() float64 {
+	if r.PreviousBest == 0 {
+		return 0
+	}
+	return r.Best - r.PreviousBest
+}
+
+// Failure counts the failed searches of a route within the period.
+type Failure struct {
+	FromAirport string
+	ToAirport   string
+	Count       int
+	LastUrl     string
+}
+
+type Digest struct {
+	Title    string
+	Start    time.Time
+	End      time.Time
+	Routes   []Route
+	Failures []Failure
+}
+
+// Build creates the digest of the period [start, end) from the stored
+// offers and failed searches. history must contain the offers before
+// start too, so changes and new lows can be told apart.
+func Build(title string, start time.Time, end time.Time, history []db.AirlineOffer, failed []db.AirlineOffer) *Digest {
+	previousStart := start.Add(-end.Sub(start))
+
+	routes := make(map[string]*Route)
+	previous := make(map[string]float64)
+	lowest := make(map[string]float64)
+
+	for _, o := range history {
+		// prices of different trip modes or currencies don't compare
+		key := o.FromAirport + "-" + o.ToAirport + " " + o.TripMode + " " + o.Currency
+
+		switch {
+		case o.CreatedOn.Before(start):
+			if l, ok := lowest[key]; !ok || o.Price < l {
+				lowest[key] = o.Price
+			}
+			if !o.CreatedOn.Before(previousStart) {
+				if p, ok := previous[key]; !ok || o.Price < p {
+					previous[key] = o.Price
+				}
+			}
+		case o.CreatedOn.Before(end):
+			r, ok := routes[key]
+			if !ok {
+				r = &Route{FromAirport: o.FromAirport, ToAirport: o.ToAirport, TripMode: o.TripMode, Currency: o.Currency}
+				routes[key] = r
+			}
+			r.Offers++
+			if r.Offers == 1 || o.Price < r.Best {
+				r.Best = o.Price
+				r.DepartureDate = o.DepartureDate
+				r.ReturnDate = o.ReturnDate
+				r.Url = o.Url
+			}
+		}
+	}
+
+	d := &Digest{Title: title, Start: start, End: end}
+
+	for key, r := range routes {
+		r.PreviousBest = previous[key]
+		if l, ok := lowest[key]; ok && r.Best < l {
+			r.NewLow = true
+		}
+		d.Routes = append(d.Routes, *r)
+	}
+	sort.Slice(d.Routes, func(i, j int) bool { return d.Routes[i].Name() < d.Routes[j].Name() })
+
+	failures := make(map[string]*Failure)
+	for _, o := range failed {
+		if o.CreatedOn.Before(start) || !o.CreatedOn.Before(end) {
+			continue
+		}
+		key := o.FromAirport + "-" + o.ToAirport
+		f, ok := failures[key]
+		if !ok {
+			f = &Failure{FromAirport: o.FromAirport, ToAirport: o.ToAirport}
+			failures[key] = f
+		}
+		f.Count++
+		f.LastUrl = o.Url
+	}
+	for _, f := range failures {
+		d.Failures = append(d.Failures, *f)
+	}
+	sort.Slice(d.Failures, func(i, j int) bool {
+		return d.Failures[i].FromAirport+d.Failures[i].ToAirport < d.Failures[j].FromAirport+d.Failures[j].ToAirport
+	})
+
+	return d
+}
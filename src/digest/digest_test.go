@@ -0,0 +1,151 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	db "airliner/database"
+	md "airliner/model"
+)
+
+func createOffer(from string, to string, price float64, createdOn time.Time) db.AirlineOffer {
+	return db.AirlineOffer{
+		FromAirport:   from,
+		ToAirport:     to,
+		Price:         price,
+		CreatedOn:     createdOn,
+		DepartureDate: createdOn.Add(30 * 24 * time.Hour),
+		Url:           "https://www.kayak.com/flights/" + from + "-" + to,
+	}
+}
+
+func TestBuild(t *testing.T) {
+	end := time.Date(2023, 6, 8, 0, 0, 0, 0, time.UTC)
+	start := end.Add(-7 * 24 * time.Hour)
+	day := 24 * time.Hour
+
+	history := []db.AirlineOffer{
+		createOffer("LIS", "MUC", 150, start.Add(-30*day)),
+		createOffer("LIS", "MUC", 220, start.Add(-2*day)),
+		createOffer("LIS", "MUC", 200, start.Add(day)),
+		createOffer("LIS", "MUC", 180, start.Add(2*day)),
+		createOffer("BER", "LIS", 120, start.Add(-3*day)),
+		createOffer("BER", "LIS", 100, start.Add(3*day)),
+		createOffer("OPO", "LHR", 80, start.Add(4*day)),
+		createOffer("OPO", "LHR", 10, end.Add(day)),
+	}
+	failed := []db.AirlineOffer{
+		createOffer("LIS", "MUC", 0, start.Add(day)),
+		createOffer("LIS", "MUC", 0, start.Add(2*day)),
+		createOffer("BER", "LIS", 0, start.Add(-day)),
+	}
+
+	d := Build("Weekly", start, end, history, failed)
+
+	tests := []struct {
+		name     string
+		best     float64
+		offers   int
+		previous float64
+		newLow   bool
+	}{
+		{"BER-LIS", 100, 1, 120, true},
+		{"LIS-MUC", 180, 2, 220, false},
+		{"OPO-LHR", 80, 1, 0, false},
+	}
+
+	if len(d.Routes) != len(tests) {
+		t.Fatalf("expected %d routes, got %d", len(tests), len(d.Routes))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := d.Routes[i]
+			if r.Name() != tt.name {
+				t.Fatalf("expected route %s, got %s", tt.name, r.Name())
+			}
+			if r.Best != tt.best || r.Offers != tt.offers || r.PreviousBest != tt.previous || r.NewLow != tt.newLow {
+				t.Errorf("unexpected route %+v", r)
+			}
+		})
+	}
+
+	if len(d.Failures) != 1 || d.Failures[0].Count != 2 || d.Failures[0].FromAirport != "LIS" {
+		t.Errorf("unexpected failures %+v", d.Failures)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	end := time.Date(2023, 6, 8, 0, 0, 0, 0, time.UTC)
+	start := end.Add(-24 * time.Hour)
+
+	d := Build("Daily <digest>", start, end, []db.AirlineOffer{
+		createOffer("LIS", "MUC", 150, start.Add(-time.Hour)),
+		createOffer("LIS", "MUC", 120, start.Add(time.Hour)),
+	}, nil)
+
+	email, err := EmailHTML(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{"markdown", Markdown(d), []string{"# Daily <digest>", "| [LIS-MUC](https://www.kayak.com/flights/LIS-MUC) | 120.00 |", "-30.00", "**new low**"}},
+		{"telegram", TelegramHTML(d), []string{"<b>Daily &lt;digest&gt;</b>", ">LIS-MUC</a></b>: 120.00", "change -30.00", "new low"}},
+		{"email", email, []string{"<h2>Daily &lt;digest&gt;</h2>", ">LIS-MUC</a>", "120.00", "-30.00", "new low"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, w := range tt.want {
+				if !strings.Contains(tt.output, w) {
+					t.Errorf("expected %q in output:\n%s", w, tt.output)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildSeparatesTripModesAndCurrencies(t *testing.T) {
+	end := time.Date(2023, 6, 8, 0, 0, 0, 0, time.UTC)
+	start := end.Add(-7 * 24 * time.Hour)
+
+	round := createOffer("LIS", "MUC", 300, start.Add(time.Hour))
+	round.TripMode, round.Currency = md.RoundTrip, "EUR"
+	single := createOffer("LIS", "MUC", 120, start.Add(time.Hour))
+	single.TripMode, single.Currency = md.SingleTrip, "EUR"
+	dollars := createOffer("LIS", "MUC", 250, start.Add(time.Hour))
+	dollars.TripMode, dollars.Currency = md.RoundTrip, "USD"
+	before := createOffer("LIS", "MUC", 110, start.Add(-time.Hour))
+	before.TripMode, before.Currency = md.SingleTrip, "EUR"
+
+	d := Build("Weekly", start, end, []db.AirlineOffer{before, round, single, dollars}, nil)
+
+	want := []string{"LIS-MUC 300.00 EUR", "LIS-MUC 250.00 USD", "LIS-MUC one-way 120.00 EUR"}
+	if len(d.Routes) != len(want) {
+		t.Fatalf("expected %d routes, got %+v", len(want), d.Routes)
+	}
+	got := map[string]*Route{}
+	for i := range d.Routes {
+		r := &d.Routes[i]
+		got[r.Name()+" "+r.BestPrice()] = r
+	}
+	for _, w := range want {
+		if got[w] == nil {
+			t.Errorf("expected route %s, got %+v", w, d.Routes)
+		}
+	}
+
+	// only the one-way offer before the period compares with the one-way one
+	if r := got["LIS-MUC one-way 120.00 EUR"]; r != nil && r.PreviousBest != 110 {
+		t.Errorf("expected the previous one-way best of 110, got %.2f", r.PreviousBest)
+	}
+	if r := got["LIS-MUC 300.00 EUR"]; r != nil && r.PreviousBest != 0 {
+		t.Errorf("expected no previous round trip best, got %.2f", r.PreviousBest)
+	}
+}
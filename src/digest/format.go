@@ -0,0 +1,142 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+)
+
+func formatDates(departure time.Time, ret time.Time) string {
+	s := departure.Format("2006-01-02")
+	if !ret.IsZero() {
+		s += " – " + ret.Format("2006-01-02")
+	}
+	return s
+}
+
+func formatChange(r *Route) string {
+	if r.PreviousBest == 0 {
+		return "new"
+	}
+	return fmt.Sprintf("%+.2f", r.Change())
+}
+
+func (d *Digest) period() string {
+	return fmt.Sprintf("%s – %s", d.Start.Format("2006-01-02 15:04"), d.End.Format("2006-01-02 15:04"))
+}
+
+// Markdown renders the digest as a Markdown document.
+func Markdown(d *Digest) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n%s\n\n", d.Title, d.period())
+
+	if len(d.Routes) == 0 {
+		b.WriteString("No offers were stored in this period.\n")
+	} else {
+		b.WriteString("| Route | Best price | Dates | Change | Offers | |\n")
+		b.WriteString("|---|---:|---|---:|---:|---|\n")
+		for _, r := range d.Routes {
+			newLow := ""
+			if r.NewLow {
+				newLow = "**new low**"
+			}
+			fmt.Fprintf(&b, "| [%s](%s) | %s | %s | %s | %d | %s |\n",
+				r.Name(), r.Url, r.BestPrice(), formatDates(r.DepartureDate, r.ReturnDate), formatChange(&r), r.Offers, newLow)
+		}
+	}
+
+	if len(d.Failures) > 0 {
+		b.WriteString("\n## Failed searches\n\n")
+		for _, f := range d.Failures {
+			fmt.Fprintf(&b, "- %s-%s: %d failed, last [here](%s)\n", f.FromAirport, f.ToAirport, f.Count, f.LastUrl)
+		}
+	}
+
+	return b.String()
+}
+
+// TelegramHTML renders the digest using the HTML subset Telegram accepts.
+func TelegramHTML(d *Digest) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<b>%s</b>\n<i>%s</i>\n\n", html.EscapeString(d.Title), d.period())
+
+	if len(d.Routes) == 0 {
+		b.WriteString("No offers were stored in this period.\n")
+	}
+	for _, r := range d.Routes {
+		fmt.Fprintf(&b, "<b><a href=\"%s\">%s</a></b>: %s (%s), change %s",
+			html.EscapeString(r.Url), r.Name(), r.BestPrice(), formatDates(r.DepartureDate, r.ReturnDate), formatChange(&r))
+		if r.NewLow {
+			b.WriteString(" 🆕 <b>new low</b>")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(d.Failures) > 0 {
+		b.WriteString("\n<b>Failed searches</b>\n")
+		for _, f := range d.Failures {
+			fmt.Fprintf(&b, "%s-%s: %d\n", f.FromAirport, f.ToAirport, f.Count)
+		}
+	}
+
+	return b.String()
+}
+
+var emailTemplate = template.Must(template.New("email").Funcs(template.FuncMap{
+	"dates":  formatDates,
+	"change": formatChange,
+}).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h2>{{.Title}}</h2>
+<p>{{.Period}}</p>
+{{if .Routes}}
+<table cellpadding="6" style="border-collapse: collapse">
+<tr style="background: #eee"><th align="left">Route</th><th align="right">Best price</th><th align="left">Dates</th><th align="right">Change</th><th align="right">Offers</th><th></th></tr>
+{{range .Routes}}
+<tr>
+<td><a href="{{.Url}}">{{.Name}}</a></td>
+<td align="right">{{.BestPrice}}</td>
+<td>{{dates .DepartureDate .ReturnDate}}</td>
+<td align="right">{{change .}}</td>
+<td align="right">{{.Offers}}</td>
+<td>{{if .NewLow}}<b style="color: #080">new low</b>{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No offers were stored in this period.</p>
+{{end}}
+{{if .Failures}}
+<h3>Failed searches</h3>
+<ul>
+{{range .Failures}}<li>{{.FromAirport}}-{{.ToAirport}}: {{.Count}} failed, last <a href="{{.LastUrl}}">here</a></li>
+{{end}}
+</ul>
+{{end}}
+</body>
+</html>
+`))
+
+// EmailHTML renders the digest as an HTML email body.
+func EmailHTML(d *Digest) (string, error) {
+	routes := make([]*Route, len(d.Routes))
+	for i := range d.Routes {
+		routes[i] = &d.Routes[i]
+	}
+
+	var buff bytes.Buffer
+	err := emailTemplate.Execute(&buff, struct {
+		Title    string
+		Period   string
+		Routes   []*Route
+		Failures []Failure
+	}{d.Title, d.period(), routes, d.Failures})
+
+	return buff.String(), err
+}
@@ -0,0 +1,70 @@
+package kayak
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	md "airliner/model"
+)
+
+var (
+	confidenceRegex = regexp.MustCompile(`(\d{1,3})\s*%`)
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// parseAdvice reads Kayak's price prediction in the words of the market,
+// e.g. "Our advice Buy now Prices are unlikely to decrease within 7 days
+// Track prices". An advice without a recommendation only keeps the text.
+func parseAdvice(text string, market *Market) *md.Advice {
+	p := market.patterns()
+	w := &p.advice
+
+	text = strings.TrimSpace(whitespaceRegex.ReplaceAllString(strings.ToLower(text), " "))
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "our advice"), "track prices")
+	text = strings.TrimSpace(text)
+
+	advice := &md.Advice{Text: text}
+
+	switch {
+	case containsAny(text, w.buy):
+		advice.Recommendation = md.AdviceBuy
+	case containsAny(text, w.wait):
+		advice.Recommendation = md.AdviceWait
+	}
+
+	rises := containsAny(text, w.rise)
+	drops := containsAny(text, w.drop)
+
+	switch {
+	case containsAny(text, w.unlikely) && (rises || drops):
+		advice.Trend = md.TrendStable
+	case rises:
+		advice.Trend = md.TrendRise
+	case drops:
+		advice.Trend = md.TrendDrop
+	case containsAny(text, w.stable):
+		advice.Trend = md.TrendStable
+	}
+
+	if m := confidenceRegex.FindStringSubmatch(text); m != nil {
+		advice.Confidence, _ = strconv.Atoi(m[1])
+	}
+	for _, r := range p.within {
+		if m := r.FindStringSubmatch(text); m != nil {
+			advice.WithinDays, _ = strconv.Atoi(m[1])
+			break
+		}
+	}
+
+	return advice
+}
+
+func containsAny(text string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(text, w) {
+			return true
+		}
+	}
+	return false
+}
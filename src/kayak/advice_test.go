@@ -0,0 +1,70 @@
+package kayak
+
+import (
+	"testing"
+
+	md "airliner/model"
+)
+
+func TestParseAdvice(t *testing.T) {
+	tests := []struct {
+		text           string
+		recommendation string
+		trend          string
+		confidence     int
+		withinDays     int
+	}{
+		{"Our advice\nBuy now\nPrices are unlikely to decrease within 7 days\nTrack prices", md.AdviceBuy, md.TrendStable, 0, 7},
+		{"our advice wait prices may drop within 5 days (78% confidence)", md.AdviceWait, md.TrendDrop, 78, 5},
+		{"Buy now Prices may rise", md.AdviceBuy, md.TrendRise, 0, 0},
+		{"Loading...", "", "", 0, 0},
+	}
+
+	market := markets["com"]
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			a := parseAdvice(tt.text, &market)
+
+			if a.Recommendation != tt.recommendation {
+				t.Errorf("Recommendation should be '%s' but got '%s'", tt.recommendation, a.Recommendation)
+			}
+			if a.Trend != tt.trend {
+				t.Errorf("Trend should be '%s' but got '%s'", tt.trend, a.Trend)
+			}
+			if a.Confidence != tt.confidence {
+				t.Errorf("Confidence should be %d but got %d", tt.confidence, a.Confidence)
+			}
+			if a.WithinDays != tt.withinDays {
+				t.Errorf("WithinDays should be %d but got %d", tt.withinDays, a.WithinDays)
+			}
+		})
+	}
+}
+
+func TestParseAdviceLocales(t *testing.T) {
+	tests := []struct {
+		market         string
+		text           string
+		recommendation string
+		trend          string
+		withinDays     int
+	}{
+		{"de", "Unser Rat\nJetzt buchen\nPreise werden in den nächsten 7 Tagen wahrscheinlich steigen", md.AdviceBuy, md.TrendRise, 7},
+		{"fr", "Notre conseil\nAttendre\nLes prix pourraient baisser dans les 5 prochains jours", md.AdviceWait, md.TrendDrop, 5},
+		{"es", "Nuestro consejo\nCompra ya\nEs poco probable que los precios bajen en los próximos 7 días", md.AdviceBuy, md.TrendStable, 7},
+		{"it", "Il nostro consiglio\nAspetta\nI prezzi potrebbero scendere nei prossimi 3 giorni", md.AdviceWait, md.TrendDrop, 3},
+		{"nl", "Ons advies\nNu boeken\nPrijzen stijgen waarschijnlijk binnen 7 dagen", md.AdviceBuy, md.TrendRise, 7},
+		{"pt", "O nosso conselho\nEsperar\nOs preços podem descer nos próximos 4 dias", md.AdviceWait, md.TrendDrop, 4},
+		{"de", "Our advice\nBuy now\nPrices are unlikely to decrease within 7 days", md.AdviceBuy, md.TrendStable, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.market+" "+tt.text, func(t *testing.T) {
+			market := markets[tt.market]
+			a := parseAdvice(tt.text, &market)
+			if a.Recommendation != tt.recommendation || a.Trend != tt.trend || a.WithinDays != tt.withinDays {
+				t.Errorf("expected %s, %s within %d days, got %+v", tt.recommendation, tt.trend, tt.withinDays, a)
+			}
+		})
+	}
+}
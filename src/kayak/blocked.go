@@ -0,0 +1,79 @@
+package kayak
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// BlockedError reports that Kayak served a bot check or captcha page
+// instead of the search results.
+type BlockedError struct {
+	Url    string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("blocked by %s: %s", Provider, e.Reason)
+}
+
+// urlMarkers and htmlMarkers are signs of a bot check, looked for in the
+// lowercase url and html of a page. Result pages mention the bot check
+// urls in their scripts, so those only count in the url.
+var (
+	urlMarkers = []blockMarker{
+		{"/help/bots", "bot check page"},
+		{"/h/bots/", "bot check page"},
+		{"captcha", "captcha"},
+	}
+	htmlMarkers = []blockMarker{
+		{"id=\"px-captcha\"", "captcha"},
+		{"class=\"g-recaptcha\"", "captcha"},
+		{"class=\"h-captcha\"", "captcha"},
+		{"please confirm that you are a human", "bot check page"},
+		{"are you a person or a robot", "bot check page"},
+	}
+)
+
+type blockMarker struct {
+	text   string
+	reason string
+}
+
+// detectBlock returns the reason the page at url with the given html is
+// a bot check, or nil when it doesn't look like one.
+func detectBlock(url string, html string) *BlockedError {
+	lowerUrl := strings.ToLower(url)
+	for _, m := range urlMarkers {
+		if strings.Contains(lowerUrl, m.text) {
+			return &BlockedError{Url: url, Reason: m.reason}
+		}
+	}
+
+	html = strings.ToLower(html)
+	for _, m := range htmlMarkers {
+		if strings.Contains(html, m.text) {
+			return &BlockedError{Url: url, Reason: m.reason}
+		}
+	}
+	return nil
+}
+
+// checkBlocked looks for a bot check on the current page. A page that
+// can't be read is not reported as blocked.
+func checkBlocked(ctx *context.Context) error {
+	var url, html string
+	if err := chromedp.Run(*ctx,
+		chromedp.Location(&url),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	); err != nil {
+		return nil
+	}
+
+	if blocked := detectBlock(url, html); blocked != nil {
+		return blocked
+	}
+	return nil
+}
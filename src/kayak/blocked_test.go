@@ -0,0 +1,61 @@
+package kayak
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDetectBlock(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		html   string
+		reason string
+	}{
+		{"results", "https://www.kayak.com/flights/LIS-MUC/2023-05-01", "<div data-resultid=\"1\">$273</div>", ""},
+		{"bot page", "https://www.kayak.com/help/bots.html", "<html></html>", "bot check page"},
+		{"captcha", "https://www.kayak.com/flights/LIS-MUC/2023-05-01", "<div id=\"px-captcha\"></div>", "captcha"},
+		{"human check", "https://www.kayak.com/flights/LIS-MUC/2023-05-01", "<p>Please confirm that you are a human</p>", "bot check page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocked := detectBlock(tt.url, tt.html)
+			if tt.reason == "" {
+				if blocked != nil {
+					t.Errorf("expected no block, got %s", blocked)
+				}
+				return
+			}
+			if blocked == nil {
+				t.Fatalf("expected a block with reason '%s'", tt.reason)
+			}
+			if blocked.Reason != tt.reason {
+				t.Errorf("expected reason '%s', got '%s'", tt.reason, blocked.Reason)
+			}
+		})
+	}
+}
+
+func TestDetectBlockFixture(t *testing.T) {
+	html, err := os.ReadFile("testdata/example_result.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blocked := detectBlock("https://www.kayak.com/flights/LIS-MUC/2023-05-01", string(html)); blocked != nil {
+		t.Errorf("result page taken for a bot check: %s", blocked)
+	}
+}
+
+func TestBlockedErrorReason(t *testing.T) {
+	var err error = &BlockedError{Reason: "captcha"}
+	if reason := failureReason(err); reason != "blocked" {
+		t.Errorf("expected reason blocked, got %s", reason)
+	}
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		t.Errorf("expected a BlockedError")
+	}
+}
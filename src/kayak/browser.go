@@ -0,0 +1,135 @@
+package kayak
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+// BrowserProfile is what the browser tells about itself.
+type BrowserProfile struct {
+	// UserAgents are taken in turn, one per browser.
+	UserAgents UserAgents
+	Viewport   Viewport
+	// Locale, e.g. en-US, is the language of the browser and
+	// AcceptLanguage the header sent with its requests. When empty, they
+	// follow the market's language.
+	Locale         string
+	AcceptLanguage string
+	// Timezone, e.g. Europe/Lisbon, is emulated unless empty.
+	Timezone Timezone
+	Headless bool
+	// ChromePath is the Chrome binary, found on the PATH if empty.
+	ChromePath string
+
+	next atomic.Uint32
+}
+
+// nextUserAgent returns the user agent of the next browser.
+func (p *BrowserProfile) nextUserAgent() string {
+	if len(p.UserAgents.List) == 0 {
+		return ""
+	}
+	i := p.next.Add(1) - 1
+	return p.UserAgents.List[int(i)%len(p.UserAgents.List)]
+}
+
+// allocatorOptions returns the options to start Chrome with.
+func (p *BrowserProfile) allocatorOptions(userAgent string, locale string) []chromedp.ExecAllocatorOption {
+	opts := append(
+		chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", p.Headless),
+		chromedp.WindowSize(p.Viewport.Width, p.Viewport.Height),
+	)
+	if userAgent != "" {
+		opts = append(opts, chromedp.UserAgent(userAgent))
+	}
+	if locale != "" {
+		opts = append(opts, chromedp.Flag("lang", locale))
+	}
+	if p.ChromePath != "" {
+		opts = append(opts, chromedp.ExecPath(p.ChromePath))
+	}
+	return opts
+}
+
+// emulate returns the actions applying the profile to a tab.
+func (p *BrowserProfile) emulate(userAgent string, locale string, acceptLanguage string) chromedp.Tasks {
+	tasks := chromedp.Tasks{
+		emulation.SetDeviceMetricsOverride(int64(p.Viewport.Width), int64(p.Viewport.Height), 1.0, false),
+	}
+	if userAgent != "" {
+		tasks = append(tasks, emulation.SetUserAgentOverride(userAgent).WithAcceptLanguage(acceptLanguage))
+	}
+	if locale != "" {
+		tasks = append(tasks, emulation.SetLocaleOverride().WithLocale(strings.ReplaceAll(locale, "-", "_")))
+	}
+	if p.Timezone != "" {
+		tasks = append(tasks, emulation.SetTimezoneOverride(string(p.Timezone)))
+	}
+	return tasks
+}
+
+// UserAgents is a list of user agents set by repeating a flag. The
+// first use of the flag replaces the default list.
+type UserAgents struct {
+	List []string
+	set  bool
+}
+
+func (u *UserAgents) String() string {
+	return strings.Join(u.List, " | ")
+}
+
+func (u *UserAgents) Set(value string) error {
+	if value = strings.TrimSpace(value); value == "" {
+		return fmt.Errorf("empty user agent")
+	}
+	if !u.set {
+		u.List = nil
+		u.set = true
+	}
+	u.List = append(u.List, value)
+	return nil
+}
+
+// Viewport is the size of the browser window, set like "1366x768".
+type Viewport struct {
+	Width  int
+	Height int
+}
+
+func (v *Viewport) String() string {
+	return fmt.Sprintf("%dx%d", v.Width, v.Height)
+}
+
+func (v *Viewport) Set(value string) error {
+	var width, height int
+	if _, err := fmt.Sscanf(value, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid viewport '%s', should be like 1366x768", value)
+	}
+	v.Width, v.Height = width, height
+	return nil
+}
+
+// Timezone is an IANA time zone name, e.g. Europe/Lisbon.
+type Timezone string
+
+func (z *Timezone) String() string {
+	return string(*z)
+}
+
+func (z *Timezone) Set(value string) error {
+	if value == "" || value == "Local" {
+		return fmt.Errorf("invalid time zone '%s', should be like Europe/Lisbon", value)
+	}
+	if _, err := time.LoadLocation(value); err != nil {
+		return fmt.Errorf("invalid time zone '%s', should be like Europe/Lisbon", value)
+	}
+	*z = Timezone(value)
+	return nil
+}
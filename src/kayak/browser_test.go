@@ -0,0 +1,72 @@
+package kayak
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestViewportSet(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Viewport
+		err   bool
+	}{
+		{"1366x768", Viewport{1366, 768}, false},
+		{"800x600", Viewport{800, 600}, false},
+		{"1366", Viewport{}, true},
+		{"0x768", Viewport{}, true},
+		{"widexhigh", Viewport{}, true},
+	}
+
+	for _, tt := range tests {
+		var v Viewport
+		err := v.Set(tt.value)
+		if tt.err != (err != nil) {
+			t.Errorf("%s: expected error %t, got %v", tt.value, tt.err, err)
+		}
+		if !tt.err && v != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.value, tt.want, v)
+		}
+	}
+}
+
+func TestTimezoneSet(t *testing.T) {
+	tests := []struct {
+		value string
+		err   bool
+	}{
+		{"Europe/Lisbon", false},
+		{"UTC", false},
+		{"Europe/Nowhere", true},
+		{"Local", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		var z Timezone
+		err := z.Set(tt.value)
+		if tt.err != (err != nil) {
+			t.Errorf("%s: expected error %t, got %v", tt.value, tt.err, err)
+		}
+		if !tt.err && string(z) != tt.value {
+			t.Errorf("%s: expected it to be set, got %s", tt.value, z)
+		}
+	}
+}
+
+func TestUserAgentRotation(t *testing.T) {
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.AddFlags(fs)
+
+	if err := fs.Parse([]string{"-kayak-user-agent", "first", "-kayak-user-agent", "second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first", "second", "first"}
+	for i, w := range want {
+		if got := cfg.Browser.nextUserAgent(); got != w {
+			t.Errorf("browser %d: expected user agent %s, got %s", i, w, got)
+		}
+	}
+}
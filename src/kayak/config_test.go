@@ -0,0 +1,48 @@
+package kayak
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"airliner/ratelimit"
+)
+
+func TestShare(t *testing.T) {
+	server := DefaultConfig()
+	server.Market = markets["de"]
+	server.RequestsPerMinute = 2
+	server.CoolOff = time.Hour
+	server.Budget, _ = ratelimit.LoadBudget("")
+	server.Proxies = "http://one:8080,http://two:8080"
+	server.ProxyMaxBlocks = 1
+	if err := server.LoadProxies(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := DefaultConfig(), DefaultConfig()
+	second.Market = markets["pt"]
+	first.Share(server)
+	second.Share(server)
+	first.init()
+	second.init()
+
+	if first.limiter == nil || first.limiter != server.limiter || second.limiter != server.limiter {
+		t.Errorf("expected the searches to share the server's limiter")
+	}
+	if first.Budget != server.Budget || first.RequestsPerMinute != 2 || first.CoolOff != time.Hour {
+		t.Errorf("expected the server's settings, got %+v", first)
+	}
+
+	// a proxy retired by one search is retired for the other too
+	px, _ := first.nextProxy()
+	first.proxyBlocked(px, slog.Default())
+	if second.proxyCount() != 2 || second.proxies.Healthy() != 1 {
+		t.Errorf("expected the searches to share the server's proxies")
+	}
+
+	com, pt := markets["com"], markets["pt"]
+	if first.Market.Name() != com.Name() || second.Market.Name() != pt.Name() {
+		t.Errorf("expected the searches to keep their market, got %s and %s", first.Market.Name(), second.Market.Name())
+	}
+}
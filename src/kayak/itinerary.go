@@ -0,0 +1,182 @@
+package kayak
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	md "airliner/model"
+)
+
+// rawLeg and rawResult mirror what resultsScript returns for every
+// result node on the page.
+type rawLeg struct {
+	Text     string   `json:"text"`
+	Carriers []string `json:"carriers"`
+	// Layovers are the titles of the layover airports, e.g.
+	// "2h 05m layover, Frankfurt am Main".
+	Layovers []string `json:"layovers"`
+}
+
+type rawResult struct {
+	Price string   `json:"price"`
+	Legs  []rawLeg `json:"legs"`
+}
+
+type result struct {
+	Price     string
+	Itinerary *md.Itinerary
+}
+
+var (
+	clockRegex   = regexp.MustCompile(`(?i)(\d{1,2}):(\d{2})\s*(am|pm)?(\s*\+(\d))?`)
+	airportRegex = regexp.MustCompile(`\b[A-Z]{3}\b`)
+)
+
+// parseClock returns the time of day found in s, and the day offset Kayak
+// appends to arrivals on a later day ("+1").
+func parseClock(match []string) (time.Duration, int) {
+	h, _ := strconv.Atoi(match[1])
+	m, _ := strconv.Atoi(match[2])
+
+	switch strings.ToLower(match[3]) {
+	case "am":
+		if h == 12 {
+			h = 0
+		}
+	case "pm":
+		if h != 12 {
+			h += 12
+		}
+	}
+
+	days := 0
+	if match[5] != "" {
+		days, _ = strconv.Atoi(match[5])
+	}
+
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, days
+}
+
+// parseLeg reads a leg from the text Kayak renders for it, e.g.
+// "20:00 – 22:20 MUC Franz Josef Strauss - LIS Humberto Delgado direct 3h 20m",
+// in the words of p. The layover durations are read from the titles of
+// the layover airports, or from the text when it has them. date is the
+// calendar day the leg departs on. A leg without its stops or travel
+// time returns an error, rather than passing the filters as a direct
+// flight of no time.
+func parseLeg(p *patterns, text string, carriers []string, layovers []string, date time.Time) (md.Leg, error) {
+	leg := md.Leg{}
+
+	layoverTimes := p.layover.FindAllStringSubmatch(text, -1)
+	if len(layovers) > 0 {
+		layoverTimes = p.layover.FindAllStringSubmatch(strings.Join(layovers, "\n"), -1)
+	}
+	// a layover's time isn't the leg's travel time
+	text = p.layover.ReplaceAllString(text, "")
+
+	clocks := clockRegex.FindAllStringSubmatch(text, 2)
+	if len(clocks) == 2 {
+		dep, _ := parseClock(clocks[0])
+		arr, days := parseClock(clocks[1])
+		leg.Departure = date.Add(dep)
+		leg.Arrival = date.Add(time.Duration(days)*md.Day + arr)
+	}
+
+	loc := p.stops.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return leg, fmt.Errorf("no stops in leg '%s'", text)
+	}
+	stopsIdx := loc[3]
+	if loc[4] != -1 {
+		leg.Stops, _ = strconv.Atoi(text[loc[4]:loc[5]])
+	}
+
+	airports := airportRegex.FindAllString(text[:stopsIdx], -1)
+	if len(airports) >= 2 {
+		leg.FromAirport = airports[0]
+		leg.ToAirport = airports[1]
+	}
+
+	if leg.Stops > 0 {
+		for _, a := range airportRegex.FindAllString(text[stopsIdx:], leg.Stops) {
+			leg.Layovers = append(leg.Layovers, md.Layover{Airport: a})
+		}
+		for i, m := range layoverTimes {
+			if i == len(leg.Layovers) {
+				break
+			}
+			leg.Layovers[i].Duration = parseDuration(m[1] + m[2])
+		}
+	}
+
+	d := p.duration.FindString(text[stopsIdx:])
+	if d == "" {
+		return leg, fmt.Errorf("no travel time in leg '%s'", text)
+	}
+	leg.Duration = parseDuration(d)
+
+	for _, c := range carriers {
+		leg.Airlines = append(leg.Airlines, carrierCode(c))
+	}
+
+	return leg, nil
+}
+
+// carrierCode turns a logo url like ".../airlines/v/TP.png" into "TP".
+func carrierCode(src string) string {
+	base := path.Base(src)
+	return strings.ToUpper(strings.TrimSuffix(base, path.Ext(base)))
+}
+
+// parseResult builds the itinerary of a result. legDates holds the
+// departure day of every leg in order. A result with a leg that couldn't
+// be read is returned without an itinerary, along with the error.
+func parseResult(p *patterns, raw rawResult, legDates []time.Time) (result, error) {
+	it := &md.Itinerary{}
+
+	for i, l := range raw.Legs {
+		var date time.Time
+		if i < len(legDates) {
+			date = legDates[i]
+		}
+		leg, err := parseLeg(p, l.Text, l.Carriers, l.Layovers, date)
+		if err != nil {
+			return result{Price: raw.Price}, err
+		}
+		it.Legs = append(it.Legs, leg)
+	}
+
+	return result{Price: raw.Price, Itinerary: it}, nil
+}
+
+// selectResult returns the first result satisfying the filter. Results
+// come sorted by price, so that is also the cheapest one. When nothing
+// matches the first result is returned with ok set to false.
+func selectResult(results []result, filter *md.Filter, tripMode string) (result, bool) {
+	for _, r := range results {
+		if filter.Match(r.Itinerary, tripMode) {
+			return r, true
+		}
+	}
+
+	return results[0], false
+}
+
+func legDates(payload *md.Payload) []time.Time {
+	if payload.IsMultiCity() {
+		dates := make([]time.Time, len(payload.Legs))
+		for i, l := range payload.Legs {
+			dates[i] = l.Date
+		}
+		return dates
+	}
+
+	if payload.ReturnDate.IsZero() {
+		return []time.Time{payload.DepartureDate}
+	}
+	return []time.Time{payload.DepartureDate, payload.ReturnDate}
+}
@@ -0,0 +1,233 @@
+package kayak
+
+import (
+	"testing"
+	"time"
+
+	md "airliner/model"
+)
+
+func TestParseLeg(t *testing.T) {
+	date := createDate("2023-03-15")
+
+	tests := []struct {
+		name         string
+		text         string
+		carriers     []string
+		titles       []string
+		from         string
+		to           string
+		departure    time.Time
+		arrival      time.Time
+		duration     time.Duration
+		stops        int
+		layovers     []string
+		layoverTimes []time.Duration
+		airlines     []string
+	}{
+		{
+			name:      "direct",
+			text:      "20:00 – 22:20\nMUC Franz Josef Strauss - LIS Humberto Delgado\ndirect\n3h 20m",
+			carriers:  []string{"kayak_example_files/TP.png"},
+			from:      "MUC",
+			to:        "LIS",
+			departure: date.Add(20 * time.Hour),
+			arrival:   date.Add(22*time.Hour + 20*time.Minute),
+			duration:  3*time.Hour + 20*time.Minute,
+			airlines:  []string{"TP"},
+		},
+		{
+			name:         "one stop overnight",
+			text:         "9:15 pm – 8:05 am+1\nLIS Humberto Delgado - MUC Franz Josef Strauss\n1 stop\nFRA\n10h 50m",
+			carriers:     []string{"/rimg/provider-logos/airlines/v/LH.png"},
+			from:         "LIS",
+			to:           "MUC",
+			departure:    date.Add(21*time.Hour + 15*time.Minute),
+			arrival:      date.Add(md.Day + 8*time.Hour + 5*time.Minute),
+			duration:     10*time.Hour + 50*time.Minute,
+			stops:        1,
+			layovers:     []string{"FRA"},
+			layoverTimes: []time.Duration{0},
+			airlines:     []string{"LH"},
+		},
+		{
+			name:         "one stop with layover title",
+			text:         "9:15 pm – 8:05 am+1\nLIS Humberto Delgado - MUC Franz Josef Strauss\n1 stop\nFRA\n10h 50m",
+			carriers:     []string{"/rimg/provider-logos/airlines/v/LH.png"},
+			titles:       []string{"2h 05m layover, Frankfurt am Main"},
+			from:         "LIS",
+			to:           "MUC",
+			departure:    date.Add(21*time.Hour + 15*time.Minute),
+			arrival:      date.Add(md.Day + 8*time.Hour + 5*time.Minute),
+			duration:     10*time.Hour + 50*time.Minute,
+			stops:        1,
+			layovers:     []string{"FRA"},
+			layoverTimes: []time.Duration{2*time.Hour + 5*time.Minute},
+			airlines:     []string{"LH"},
+		},
+		{
+			name:         "two stops with layovers in the text",
+			text:         "6:00 – 19:30\nLIS Humberto Delgado - MUC Franz Josef Strauss\n2 stops\nMAD 45m layover\nFRA 3h 10m layover\n12h 30m",
+			carriers:     []string{"/rimg/provider-logos/airlines/v/IB.png"},
+			from:         "LIS",
+			to:           "MUC",
+			departure:    date.Add(6 * time.Hour),
+			arrival:      date.Add(19*time.Hour + 30*time.Minute),
+			duration:     12*time.Hour + 30*time.Minute,
+			stops:        2,
+			layovers:     []string{"MAD", "FRA"},
+			layoverTimes: []time.Duration{45 * time.Minute, 3*time.Hour + 10*time.Minute},
+			airlines:     []string{"IB"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leg, err := parseLeg(english, tt.text, tt.carriers, tt.titles, date)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if leg.FromAirport != tt.from || leg.ToAirport != tt.to {
+				t.Errorf("route should be %s-%s but got %s-%s", tt.from, tt.to, leg.FromAirport, leg.ToAirport)
+			}
+			if !leg.Departure.Equal(tt.departure) {
+				t.Errorf("Departure should be %s but got %s", tt.departure, leg.Departure)
+			}
+			if !leg.Arrival.Equal(tt.arrival) {
+				t.Errorf("Arrival should be %s but got %s", tt.arrival, leg.Arrival)
+			}
+			if leg.Duration != tt.duration {
+				t.Errorf("Duration should be %s but got %s", tt.duration, leg.Duration)
+			}
+			if leg.Stops != tt.stops {
+				t.Errorf("Stops should be %d but got %d", tt.stops, leg.Stops)
+			}
+			if len(leg.Layovers) != len(tt.layovers) {
+				t.Fatalf("expected %d layovers but got %d", len(tt.layovers), len(leg.Layovers))
+			}
+			for i, l := range tt.layovers {
+				if leg.Layovers[i].Airport != l {
+					t.Errorf("layover %d should be %s but got %s", i, l, leg.Layovers[i].Airport)
+				}
+				if leg.Layovers[i].Duration != tt.layoverTimes[i] {
+					t.Errorf("layover %d should take %s but got %s", i, tt.layoverTimes[i], leg.Layovers[i].Duration)
+				}
+			}
+			if len(leg.Airlines) != 1 || leg.Airlines[0] != tt.airlines[0] {
+				t.Errorf("Airlines should be %v but got %v", tt.airlines, leg.Airlines)
+			}
+		})
+	}
+}
+
+var english = languagePatterns["en"]
+
+func TestParseLegLocales(t *testing.T) {
+	date := createDate("2023-03-15")
+
+	tests := []struct {
+		market   string
+		text     string
+		stops    int
+		duration time.Duration
+		layovers []time.Duration
+	}{
+		{"de", "20:00 – 22:20\nMUC Franz Josef Strauß - LIS Humberto Delgado\nDirekt\n3 Std. 20 Min.", 0, 3*time.Hour + 20*time.Minute, nil},
+		{"de", "21:15 – 08:05+1\nLIS Humberto Delgado - MUC Franz Josef Strauß\n1 Stopp\nFRA 2 Std. 5 Min. Aufenthalt\n10 Std. 50 Min.", 1, 10*time.Hour + 50*time.Minute, []time.Duration{2*time.Hour + 5*time.Minute}},
+		{"fr", "20:00 – 22:20\nMUC Franz Josef Strauss - LIS Humberto Delgado\nsans escale\n3 h 20", 0, 3*time.Hour + 20*time.Minute, nil},
+		{"fr", "21:15 – 08:05+1\nLIS Humberto Delgado - MUC Franz Josef Strauss\n2 escales\nMAD, FRA\n10 h 50 min", 2, 10*time.Hour + 50*time.Minute, []time.Duration{0, 0}},
+		{"es", "20:00 – 22:20\nMUC Franz Josef Strauss - LIS Humberto Delgado\ndirecto\n3 h 20 min", 0, 3*time.Hour + 20*time.Minute, nil},
+		{"es", "21:15 – 08:05+1\nLIS Humberto Delgado - MUC Franz Josef Strauss\n1 escala\nFRA escala de 2 h 5 min\n10 h 50 min", 1, 10*time.Hour + 50*time.Minute, []time.Duration{2*time.Hour + 5*time.Minute}},
+		{"it", "21:15 – 08:05+1\nLIS Humberto Delgado - MUC Franz Josef Strauss\n1 scalo\nFRA\n10 h 50 min", 1, 10*time.Hour + 50*time.Minute, []time.Duration{0}},
+		{"nl", "20:00 – 22:20\nMUC Franz Josef Strauss - LIS Humberto Delgado\nrechtstreeks\n3u 20m", 0, 3*time.Hour + 20*time.Minute, nil},
+		{"nl", "21:15 – 08:05+1\nLIS Humberto Delgado - MUC Franz Josef Strauss\n1 tussenstop\nFRA\n10u 50m", 1, 10*time.Hour + 50*time.Minute, []time.Duration{0}},
+		{"pt", "21:15 – 08:05+1\nLIS Humberto Delgado - MUC Franz Josef Strauss\n1 escala\nFRA\n10h 50min", 1, 10*time.Hour + 50*time.Minute, []time.Duration{0}},
+		// Kayak serves English pages on other markets too
+		{"de", "20:00 – 22:20\nMUC Franz Josef Strauss - LIS Humberto Delgado\ndirect\n3h 20m", 0, 3*time.Hour + 20*time.Minute, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.market+" "+tt.text, func(t *testing.T) {
+			market := markets[tt.market]
+			leg, err := parseLeg(market.patterns(), tt.text, nil, nil, date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if leg.FromAirport == "" || leg.Stops != tt.stops || leg.Duration != tt.duration {
+				t.Errorf("expected %d stops in %s, got %+v", tt.stops, tt.duration, leg)
+			}
+			if len(leg.Layovers) != len(tt.layovers) {
+				t.Fatalf("expected %d layovers, got %+v", len(tt.layovers), leg.Layovers)
+			}
+			for i, d := range tt.layovers {
+				if leg.Layovers[i].Duration != d {
+					t.Errorf("layover %d should take %s but got %s", i, d, leg.Layovers[i].Duration)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLegUnreadable(t *testing.T) {
+	market := markets["de"]
+	for _, text := range []string{
+		"20:00 – 22:20\nMUC Franz Josef Strauss - LIS Humberto Delgado\n3 Std. 20 Min.",
+		"20:00 – 22:20\nMUC Franz Josef Strauss - LIS Humberto Delgado\nDirekt",
+	} {
+		if _, err := parseLeg(market.patterns(), text, nil, nil, createDate("2023-03-15")); err == nil {
+			t.Errorf("expected an error for %q", text)
+		}
+	}
+
+	raw := rawResult{Price: "199 €", Legs: []rawLeg{{Text: "MUC - LIS\n2 Zwischenstopps"}}}
+	if r, err := parseResult(market.patterns(), raw, nil); err == nil || r.Itinerary != nil || r.Price != "199 €" {
+		t.Errorf("expected the result without itinerary and an error, got %+v, %v", r, err)
+	}
+}
+
+func TestSelectResult(t *testing.T) {
+	oneStop := &md.Itinerary{Legs: []md.Leg{{Stops: 1, Duration: 26 * time.Hour, Layovers: []md.Layover{{Airport: "FRA", Duration: 20 * time.Hour}}, Airlines: []string{"FR"}}}}
+	roundTrip := &md.Itinerary{Legs: []md.Leg{
+		{Stops: 1, Duration: 7 * time.Hour, Layovers: []md.Layover{{Airport: "MAD", Duration: 2 * time.Hour}}, Airlines: []string{"IB"}},
+		{Stops: 0, Duration: 6 * time.Hour, Airlines: []string{"IB"}},
+	}}
+	direct := &md.Itinerary{Legs: []md.Leg{{Stops: 0, Duration: 3 * time.Hour, Airlines: []string{"TP"}}, {Stops: 0, Duration: 3 * time.Hour, Airlines: []string{"TP"}}}}
+
+	results := []result{
+		{Price: "199", Itinerary: oneStop},
+		{Price: "240", Itinerary: roundTrip},
+		{Price: "273", Itinerary: direct},
+	}
+
+	tests := []struct {
+		name     string
+		filter   md.Filter
+		price    string
+		excluded bool
+	}{
+		{"no filter", md.Filter{MaxStops: md.AnyStops}, "199", false},
+		{"direct only", md.Filter{MaxStops: 0}, "273", false},
+		{"max duration per leg", md.Filter{MaxStops: md.AnyStops, MaxDuration: 20 * time.Hour}, "240", false},
+		{"max total duration", md.Filter{MaxStops: md.AnyStops, MaxDuration: 12 * time.Hour}, "273", false},
+		{"max layover", md.Filter{MaxStops: md.AnyStops, MaxLayover: 4 * time.Hour}, "240", false},
+		{"min layover", md.Filter{MaxStops: md.AnyStops, MinLayover: 3 * time.Hour}, "199", false},
+		{"excluded airline", md.Filter{MaxStops: md.AnyStops, ExcludedAirlines: []string{"FR"}}, "240", false},
+		{"nothing matches", md.Filter{MaxStops: md.AnyStops, Airlines: []string{"LH"}}, "199", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := selectResult(results, &tt.filter, md.RoundTrip)
+			if r.Price != tt.price {
+				t.Errorf("expected price %s but got %s", tt.price, r.Price)
+			}
+			if ok == tt.excluded {
+				t.Errorf("expected match to be %t", !tt.excluded)
+			}
+			if tt.excluded && r.Itinerary != results[0].Itinerary {
+				t.Errorf("expected the cheapest result without a match")
+			}
+		})
+	}
+}
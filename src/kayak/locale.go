@@ -0,0 +1,153 @@
+package kayak
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// locale holds the words Kayak writes its results and price advice in
+// for one language. The result words are alternatives of a regexp, the
+// advice words are looked for as substrings of the lowercased advice.
+type locale struct {
+	direct  []string // a leg without stops
+	stops   []string // follows the number of stops
+	layover []string // next to a layover's duration
+
+	buy, wait, rise, drop, unlikely, stable []string
+	// within finds the number of days the advice is about.
+	within string
+}
+
+var locales = map[string]locale{
+	"en": {
+		direct: []string{"direct", "nonstop"}, stops: []string{"stops?"}, layover: []string{"layover"},
+		buy: []string{"buy"}, wait: []string{"wait", "watch"}, rise: []string{"rise", "increase"}, drop: []string{"drop", "decrease"},
+		unlikely: []string{"unlikely"}, stable: []string{"stable", "not change"},
+		within: `within\s+(\d+)\s+days?`,
+	},
+	"de": {
+		direct: []string{"direkt", "nonstop"}, stops: []string{"stopps?"}, layover: []string{"aufenthalt", "umsteigezeit"},
+		buy: []string{"kaufen", "buchen"}, wait: []string{"warten"}, rise: []string{"steigen", "anstieg"}, drop: []string{"sinken", "fallen"},
+		unlikely: []string{"unwahrscheinlich"}, stable: []string{"stabil"},
+		within: `(\d+)\s+tage`,
+	},
+	"fr": {
+		direct: []string{"direct", "sans escale"}, stops: []string{"escales?"}, layover: []string{"escale", "correspondance"},
+		buy: []string{"achet", "réserve"}, wait: []string{"attend"}, rise: []string{"augment", "hausse"}, drop: []string{"baiss", "diminu"},
+		unlikely: []string{"peu probable", "improbable"}, stable: []string{"stable"},
+		within: `(\d+)\s+(?:prochains\s+)?jours`,
+	},
+	"es": {
+		direct: []string{"directo", "sin escalas"}, stops: []string{"escalas?"}, layover: []string{"escala"},
+		buy: []string{"compr", "reserv"}, wait: []string{"esper"}, rise: []string{"subir", "suban", "aument"}, drop: []string{"bajar", "bajen", "disminu"},
+		unlikely: []string{"poco probable", "improbable"}, stable: []string{"estable"},
+		within: `(\d+)\s+días`,
+	},
+	"it": {
+		direct: []string{"diretto", "senza scali"}, stops: []string{"scal[oi]"}, layover: []string{"scalo"},
+		buy: []string{"acquist", "prenot"}, wait: []string{"aspett", "attend"}, rise: []string{"aument", "salir"}, drop: []string{"scend", "diminu", "calo"},
+		unlikely: []string{"improbabil", "poco probabil"}, stable: []string{"stabil"},
+		within: `(\d+)\s+giorni`,
+	},
+	"nl": {
+		direct: []string{"direct", "rechtstreeks", "zonder tussenstop"}, stops: []string{"tussenstops?", "overstappen", "overstap"}, layover: []string{"overstap", "tussenstop"},
+		buy: []string{"boek", "koop"}, wait: []string{"wacht"}, rise: []string{"stijg"}, drop: []string{"dal"},
+		unlikely: []string{"onwaarschijnlijk"}, stable: []string{"stabiel"},
+		within: `(\d+)\s+dagen`,
+	},
+	"pt": {
+		direct: []string{"direto", "sem escalas"}, stops: []string{"escalas?", "paragens?"}, layover: []string{"escala"},
+		buy: []string{"compr", "reserv"}, wait: []string{"esper", "aguard"}, rise: []string{"subir", "aument"}, drop: []string{"descer", "baixar", "diminu"},
+		unlikely: []string{"improvável", "pouco provável"}, stable: []string{"estáve"},
+		within: `(\d+)\s+dias`,
+	},
+}
+
+// durationPattern matches a duration like "3h 20m", "3 Std. 20 Min.",
+// "3 h 20", "3u 20m" or "45m".
+const durationPattern = `\d+\s*(?:h|std|u)\b\.?(?:\s*\d+(?:\s*(?:min|m)\b\.?)?)?|\d+\s*(?:min|m)\b\.?`
+
+var (
+	hoursRegex   = regexp.MustCompile(`(?i)(\d+)\s*(?:h|std|u)\b`)
+	minutesRegex = regexp.MustCompile(`(\d+)`)
+)
+
+// patterns are the compiled words of a language, together with the
+// English ones as Kayak serves English pages on every market.
+type patterns struct {
+	stops    *regexp.Regexp
+	duration *regexp.Regexp
+	layover  *regexp.Regexp
+	within   []*regexp.Regexp
+	advice   locale
+}
+
+var languagePatterns = compileLocales()
+
+func compileLocales() map[string]*patterns {
+	compiled := make(map[string]*patterns)
+	for lang := range locales {
+		compiled[lang] = compileLocale(lang)
+	}
+	return compiled
+}
+
+func compileLocale(lang string) *patterns {
+	l := locales["en"]
+	if lang != "en" {
+		other := locales[lang]
+		l = locale{
+			direct:   append(other.direct, l.direct...),
+			stops:    append(other.stops, l.stops...),
+			layover:  append(other.layover, l.layover...),
+			buy:      append(other.buy, l.buy...),
+			wait:     append(other.wait, l.wait...),
+			rise:     append(other.rise, l.rise...),
+			drop:     append(other.drop, l.drop...),
+			unlikely: append(other.unlikely, l.unlikely...),
+			stable:   append(other.stable, l.stable...),
+		}
+	}
+
+	layover := strings.Join(l.layover, "|")
+	p := &patterns{
+		// group 1 is the stops text, group 2 the number of stops
+		stops:    regexp.MustCompile(`(?i)(?:^|[^\pL])(` + strings.Join(l.direct, "|") + `|(\d+)\s+(?:` + strings.Join(l.stops, "|") + `))(?:[^\pL]|$)`),
+		duration: regexp.MustCompile(`(?i)` + durationPattern),
+		// group 1 is a duration before the layover word, group 2 one after
+		// it on the same line, so "sans escale" isn't read as a layover
+		layover: regexp.MustCompile(`(?i)(` + durationPattern + `)[^\S\n]+(?:` + layover + `)|(?:` + layover + `)[^\S\n]+(?:de[^\S\n]+|di[^\S\n]+|van[^\S\n]+)?(` + durationPattern + `)`),
+		advice:  l,
+	}
+	for _, w := range []string{locales[lang].within, locales["en"].within} {
+		p.within = append(p.within, regexp.MustCompile(`(?i)`+w))
+	}
+	return p
+}
+
+// patterns returns the words of the market's language.
+func (m *Market) patterns() *patterns {
+	lang, _, _ := strings.Cut(m.Language, "-")
+	if p, ok := languagePatterns[lang]; ok {
+		return p
+	}
+	return languagePatterns["en"]
+}
+
+// parseDuration reads a duration matched by durationPattern.
+func parseDuration(text string) time.Duration {
+	var d time.Duration
+	rest := text
+	if loc := hoursRegex.FindStringSubmatchIndex(text); loc != nil {
+		h, _ := strconv.Atoi(text[loc[2]:loc[3]])
+		d = time.Duration(h) * time.Hour
+		rest = text[loc[1]:]
+	}
+	if m := minutesRegex.FindStringSubmatch(rest); m != nil {
+		min, _ := strconv.Atoi(m[1])
+		d += time.Duration(min) * time.Minute
+	}
+	return d
+}
@@ -0,0 +1,110 @@
+package kayak
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Market is a regional Kayak site with its currency and language.
+type Market struct {
+	Key      string // e.g. "de" for kayak.de
+	Domain   string
+	Currency string // ISO 4217 code
+	Language string
+	// DecimalComma is set when prices are written like "1.234,56".
+	DecimalComma bool
+}
+
+var markets = map[string]Market{
+	"com":   {"com", "www.kayak.com", "USD", "en-US", false},
+	"co.uk": {"co.uk", "www.kayak.co.uk", "GBP", "en-GB", false},
+	"ie":    {"ie", "www.kayak.ie", "EUR", "en-IE", false},
+	"de":    {"de", "www.kayak.de", "EUR", "de-DE", true},
+	"at":    {"at", "www.kayak.at", "EUR", "de-AT", true},
+	"ch":    {"ch", "www.kayak.ch", "CHF", "de-CH", false},
+	"pt":    {"pt", "www.kayak.pt", "EUR", "pt-PT", true},
+	"es":    {"es", "www.kayak.es", "EUR", "es-ES", true},
+	"fr":    {"fr", "www.kayak.fr", "EUR", "fr-FR", true},
+	"it":    {"it", "www.kayak.it", "EUR", "it-IT", true},
+	"nl":    {"nl", "www.kayak.nl", "EUR", "nl-NL", true},
+}
+
+// LookupMarket returns the market of a Kayak domain suffix, e.g. "de" or
+// "co.uk".
+func LookupMarket(key string) (Market, bool) {
+	m, ok := markets[strings.TrimPrefix(strings.ToLower(key), "kayak.")]
+	return m, ok
+}
+
+func marketKeys() []string {
+	keys := make([]string, 0, len(markets))
+	for k := range markets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Name returns the market like "kayak.de".
+func (m *Market) Name() string {
+	return "kayak." + m.Key
+}
+
+func (m *Market) String() string {
+	return m.Key
+}
+
+// Set selects the market of a domain suffix like "de" or "kayak.de".
+func (m *Market) Set(value string) error {
+	found, ok := LookupMarket(value)
+	if !ok {
+		return fmt.Errorf("unknown market '%s', should be one of %s", value, strings.Join(marketKeys(), ", "))
+	}
+	*m = found
+	return nil
+}
+
+// currencySigns maps the signs found in prices to their currencies.
+var currencySigns = []struct {
+	sign     string
+	currency string
+}{
+	{"€", "EUR"}, {"EUR", "EUR"},
+	{"£", "GBP"}, {"GBP", "GBP"},
+	{"CHF", "CHF"},
+	{"$", "USD"}, {"USD", "USD"},
+}
+
+// ParsePrice reads a price as the market writes it, e.g. "$1,234",
+// "1.234 €" or "CHF 1'234". Currency signs and thousands separators are
+// skipped. A price in another currency than the market's, like euros on
+// kayak.com, is an error rather than stored in the wrong currency.
+func (m *Market) ParsePrice(text string) (float64, error) {
+	for _, c := range currencySigns {
+		if strings.Contains(text, c.sign) {
+			if c.currency != m.Currency {
+				return 0, fmt.Errorf("price '%s' of %s is in %s, not %s, pick the market with --kayak-market", text, m.Name(), c.currency, m.Currency)
+			}
+			break
+		}
+	}
+
+	var digits strings.Builder
+	for _, r := range text {
+		switch {
+		case unicode.IsDigit(r):
+			digits.WriteRune(r)
+		case r == ',' && m.DecimalComma, r == '.' && !m.DecimalComma:
+			digits.WriteRune('.')
+		}
+	}
+
+	v, err := strconv.ParseFloat(digits.String(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse price '%s' of %s", text, m.Name())
+	}
+	return v, nil
+}
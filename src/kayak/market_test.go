@@ -0,0 +1,63 @@
+package kayak
+
+import "testing"
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		market string
+		text   string
+		want   float64
+	}{
+		{"com", "$273", 273},
+		{"com", "$1,234", 1234},
+		{"com", "$99.50", 99.5},
+		{"de", "273 €", 273},
+		{"de", "1.234 €", 1234},
+		{"de", "1.234,50 €", 1234.5},
+		{"pt", "273 €", 273},
+		{"fr", "1 234 €", 1234},
+		{"co.uk", "£1,234", 1234},
+		{"ch", "CHF 1’234", 1234},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.market+" "+tt.text, func(t *testing.T) {
+			m, ok := LookupMarket(tt.market)
+			if !ok {
+				t.Fatalf("unknown market %s", tt.market)
+			}
+			got, err := m.ParsePrice(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %.2f, got %.2f", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParsePriceInvalid(t *testing.T) {
+	m, _ := LookupMarket("de")
+	if _, err := m.ParsePrice("Preis unbekannt"); err == nil {
+		t.Errorf("expected an error for a text without a price")
+	}
+
+	com, _ := LookupMarket("com")
+	if _, err := com.ParsePrice("273\u00a0€"); err == nil {
+		t.Errorf("expected an error for a euro price on kayak.com")
+	}
+}
+
+func TestMarketSet(t *testing.T) {
+	var m Market
+	if err := m.Set("kayak.co.uk"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Domain != "www.kayak.co.uk" || m.Currency != "GBP" {
+		t.Errorf("unexpected market %+v", m)
+	}
+	if err := m.Set("kayak.xyz"); err == nil {
+		t.Errorf("expected an error for an unknown market")
+	}
+}
@@ -3,13 +3,13 @@ package kayak
 import (
 	"context"
 	"errors"
-	"fmt"
-	"log"
 	"strings"
 	"time"
 
 	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/chromedp"
+
+	"airliner/logging"
 )
 
 func getAdviceText(ctx *context.Context) (*string, error) {
@@ -33,44 +33,91 @@ func countResultList(ctx *context.Context) int {
 	if err := chromedp.Run(*ctx,
 		chromedp.Nodes(selector, &nodes, chromedp.AtLeast(0)),
 	); err != nil {
-		fmt.Println(err)
+		logging.FromContext(*ctx).Warn("Couldn't count result nodes", "err", err)
 		return 0
 	}
 
-	fmt.Printf("Found %d result nodes.\n", len(nodes))
+	logging.FromContext(*ctx).Debug("Found result nodes", "count", len(nodes))
 	return len(nodes)
 }
 
-func findBestOfferPrice(ctx *context.Context) *string {
-	fmt.Println("Extracting best offer...")
+func findBestOfferPrice(ctx *context.Context) (*string, error) {
+	l := logging.FromContext(*ctx)
+	l.Debug("Extracting best offer")
 	var nodes = make([]*cdp.Node, 10)
 	var result string
 
 	selector := "//div[@data-resultid]"
 
-	repeat := 3
-	for {
+	for repeat := 3; ; repeat-- {
 		if err := chromedp.Run(*ctx,
-			chromedp.Nodes(selector, &nodes),
+			chromedp.Nodes(selector, &nodes, chromedp.AtLeast(0)),
 		); err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		if len(nodes) > 0 {
 			break
-
-		} else {
-			fmt.Println("Couldn't find best offer, retrying...")
-			time.Sleep(time.Second)
-			repeat--
 		}
+		if repeat == 1 {
+			return nil, errResultsNotFound
+		}
+
+		l.Info("Couldn't find best offer, retrying")
+		time.Sleep(time.Second)
 	}
 
 	if err := chromedp.Run(*ctx,
 		chromedp.Text("[class$=price-text]", &result, chromedp.FromNode(nodes[0])),
 	); err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	l.Debug("Found best offer", "price", result)
+	return &result, nil
+}
+
+const resultsScript = `Array.from(document.querySelectorAll('div[data-resultid]')).map(r => ({
+	price: (r.querySelector('[class$=price-text]') || {}).textContent || '',
+	legs: Array.from(r.querySelectorAll('ol > li')).map(l => ({
+		text: l.innerText,
+		carriers: Array.from(l.querySelectorAll('img[src]')).map(i => i.getAttribute('src')),
+		layovers: Array.from(l.querySelectorAll('[title*=layover]')).map(s => s.getAttribute('title')),
+	})),
+}))`
+
+// findResults parses the result nodes of the page in the words of the
+// market. Results whose itinerary couldn't be read are kept without one,
+// with a warning, as the filters can't be checked on them.
+func findResults(ctx *context.Context, market *Market, legDates []time.Time) ([]result, error) {
+	var raw []rawResult
+
+	if err := chromedp.Run(*ctx,
+		chromedp.Evaluate(resultsScript, &raw),
+	); err != nil {
+		return nil, err
+	}
+
+	if len(raw) == 0 {
+		return nil, errors.New("no results found")
+	}
+
+	l := logging.FromContext(*ctx)
+	p := market.patterns()
+	unread := 0
+	results := make([]result, len(raw))
+	for i, r := range raw {
+		var err error
+		if results[i], err = parseResult(p, r, legDates); err != nil {
+			if unread == 0 {
+				l.Warn("Couldn't read itinerary, the filters can't be checked on it", "market", market.Name(), "err", err)
+			}
+			unread++
+		}
+	}
+	if unread > 0 {
+		l.Warn("Results without itinerary", "count", unread, "results", len(results))
 	}
 
-	println("Found best offer...")
-	return &result
+	l.Debug("Parsed results", "count", len(results))
+	return results, nil
 }
@@ -7,6 +7,7 @@ import (
 	"path"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/chromedp/cdproto/emulation"
 	"github.com/chromedp/chromedp"
@@ -90,7 +91,10 @@ func TestFindBestOfferPrice(t *testing.T) {
 
 	ctx, _ := testAllocate(t, "example_result.html")
 
-	bestOffer := findBestOfferPrice(&ctx)
+	bestOffer, err := findBestOfferPrice(&ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if *bestOffer != "273 €" {
 		msg := fmt.Sprintf("Expected %s to equal '273'", *bestOffer)
 		t.Log(msg)
@@ -111,3 +115,30 @@ func TestCountResultNodes(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFindResults(t *testing.T) {
+	t.Parallel()
+
+	ctx, _ := testAllocate(t, "example_result.html")
+
+	market := markets["de"]
+	results, err := findResults(&ctx, &market, []time.Time{createDate("2023-03-15"), createDate("2023-04-01")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 15 {
+		t.Errorf("Expected %d to equal: 15", len(results))
+	}
+
+	first := results[0]
+	if first.Price != "273\u00a0€" {
+		t.Errorf("Expected %s to equal '273 €'", first.Price)
+	}
+	if len(first.Itinerary.Legs) != 2 {
+		t.Fatalf("Expected 2 legs but got %d", len(first.Itinerary.Legs))
+	}
+	if leg := first.Itinerary.Legs[0]; leg.FromAirport != "MUC" || leg.ToAirport != "LIS" || leg.Stops != 0 {
+		t.Errorf("Unexpected first leg %+v", leg)
+	}
+}
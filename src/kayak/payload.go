@@ -16,27 +16,77 @@ func CreatePayloads(
 	direct bool,
 	ch chan *md.Payload,
 	wg *sync.WaitGroup,
+) {
+	template := md.Payload{
+		FromCity:      fromCity,
+		ToCity:        toCity,
+		DepartureDate: initialDate,
+		Direct:        direct,
+		Filter:        md.Filter{MaxStops: md.AnyStops},
+	}
+
+	CreatePayloadsFromTemplate(template, tripLength, daysToLookup, ch, wg)
+}
+
+// CreatePayloadsFromTemplate sends one payload per day to look up. Every
+// payload is a copy of template with the dates shifted by its Id. For
+// multi-city templates all leg dates are shifted and tripLength is ignored.
+func CreatePayloadsFromTemplate(
+	template md.Payload,
+	tripLength int,
+	daysToLookup int,
+	ch chan *md.Payload,
+	wg *sync.WaitGroup,
+) {
+	CreatePayloadsForTripLengths(template, []int{tripLength}, daysToLookup, ch, wg)
+}
+
+// CreatePayloadsForTripLengths works like CreatePayloadsFromTemplate for
+// several trip lengths at once. Ids keep counting up across trip lengths.
+func CreatePayloadsForTripLengths(
+	template md.Payload,
+	tripLengths []int,
+	daysToLookup int,
+	ch chan *md.Payload,
+	wg *sync.WaitGroup,
 ) {
 	defer close(ch)
 	defer wg.Done()
 
-	i := 0
-	for i < daysToLookup {
-		initialDate2 := initialDate.Add(time.Duration(i) * md.Day)
-		var returnDate time.Time
+	id := 0
+	for _, tripLength := range tripLengths {
+		i := 0
+		for i < daysToLookup {
+			initialDate2 := template.DepartureDate.Add(time.Duration(i) * md.Day)
+			var returnDate time.Time
 
-		if tripLength > 0 {
-			returnDate = initialDate2.Add(time.Duration(tripLength) * md.Day)
-		}
+			if tripLength > 0 {
+				returnDate = initialDate2.Add(time.Duration(tripLength) * md.Day)
+			}
 
-		ch <- &md.Payload{
-			FromCity:      fromCity,
-			ToCity:        toCity,
-			DepartureDate: initialDate2,
-			ReturnDate:    returnDate,
-			Id:            i,
-			Direct:        direct,
+			p := template
+			p.DepartureDate = initialDate2
+			p.ReturnDate = returnDate
+			p.Id = id
+
+			if template.IsMultiCity() {
+				p.Legs = shiftLegs(template.Legs, time.Duration(i)*md.Day)
+				p.DepartureDate = p.Legs[0].Date
+				p.ReturnDate = p.Legs[len(p.Legs)-1].Date
+			}
+
+			ch <- &p
+			i++
+			id++
 		}
-		i++
 	}
 }
+
+func shiftLegs(legs []md.SearchLeg, offset time.Duration) []md.SearchLeg {
+	shifted := make([]md.SearchLeg, len(legs))
+	for i, l := range legs {
+		l.Date = l.Date.Add(offset)
+		shifted[i] = l
+	}
+	return shifted
+}
@@ -64,3 +64,91 @@ func createDate(input string) time.Time {
 	v, _ := time.Parse("2006-01-02", input)
 	return v
 }
+
+func TestCreatePayloadsFromTemplateMultiCity(t *testing.T) {
+	var wg sync.WaitGroup
+
+	template := md.Payload{
+		FromCity:      "LIS",
+		ToCity:        "MUC",
+		DepartureDate: createDate("2023-05-01"),
+		Legs: []md.SearchLeg{
+			{FromCity: "LIS", ToCity: "MUC", Date: createDate("2023-05-01")},
+			{FromCity: "BER", ToCity: "LIS", Date: createDate("2023-05-10")},
+		},
+	}
+
+	ch := make(chan *md.Payload)
+
+	wg.Add(1)
+	go CreatePayloadsFromTemplate(template, 10, 2, ch, &wg)
+
+	result := []*md.Payload{}
+	for v := range ch {
+		result = append(result, v)
+	}
+
+	wg.Wait()
+
+	expected := []struct {
+		departure  time.Time
+		returndate time.Time
+		route      string
+	}{
+		{createDate("2023-05-01"), createDate("2023-05-10"), "LIS-MUC/2023-05-01/BER-LIS/2023-05-10"},
+		{createDate("2023-05-02"), createDate("2023-05-11"), "LIS-MUC/2023-05-02/BER-LIS/2023-05-11"},
+	}
+
+	for i, e := range expected {
+		if result[i].DepartureDate != e.departure {
+			t.Errorf("DepartureDate should be %s but got %s", e.departure, result[i].DepartureDate)
+		}
+		if result[i].ReturnDate != e.returndate {
+			t.Errorf("ReturnDate should be %s but got %s", e.returndate, result[i].ReturnDate)
+		}
+		if got := result[i].RouteString(); got != e.route {
+			t.Errorf("RouteString should be %s but got %s", e.route, got)
+		}
+	}
+
+	if template.Legs[0].Date != createDate("2023-05-01") {
+		t.Errorf("template legs must not be modified")
+	}
+}
+
+func TestCreatePayloadsForTripLengths(t *testing.T) {
+	var wg sync.WaitGroup
+
+	template := md.Payload{FromCity: "LIS", ToCity: "MUC", DepartureDate: createDate("2023-01-01")}
+	ch := make(chan *md.Payload)
+
+	wg.Add(1)
+	go CreatePayloadsForTripLengths(template, []int{5, 7}, 2, ch, &wg)
+
+	result := []*md.Payload{}
+	for v := range ch {
+		result = append(result, v)
+	}
+
+	wg.Wait()
+
+	expected := []struct {
+		departure  time.Time
+		returndate time.Time
+		id         int
+	}{
+		{createDate("2023-01-01"), createDate("2023-01-06"), 0},
+		{createDate("2023-01-02"), createDate("2023-01-07"), 1},
+		{createDate("2023-01-01"), createDate("2023-01-08"), 2},
+		{createDate("2023-01-02"), createDate("2023-01-09"), 3},
+	}
+
+	if len(result) != len(expected) {
+		t.Fatalf("expected %d payloads but got %d", len(expected), len(result))
+	}
+	for i, e := range expected {
+		if result[i].DepartureDate != e.departure || result[i].ReturnDate != e.returndate || result[i].Id != e.id {
+			t.Errorf("payload %d should be %s/%s id %d but got %s id %d", i, e.departure, e.returndate, e.id, result[i].DateString(), result[i].Id)
+		}
+	}
+}
@@ -4,138 +4,246 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/chromedp/cdproto/emulation"
 	"github.com/chromedp/chromedp"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"airliner/logging"
+	"airliner/metrics"
 	md "airliner/model"
+	"airliner/proxy"
+	"airliner/ratelimit"
+	"airliner/retry"
+	"airliner/tracing"
 )
 
 const TIMEOUT_MINUTES = time.Minute * 10
 const MAX_RETRIES = 5
 
-func takeScreenshot(ctx *context.Context, buff *[]byte) {
-	if err := chromedp.Run(*ctx,
-		chromedp.CaptureScreenshot(buff),
-	); err != nil {
-		log.Fatal(err)
+// Provider labels the metrics of this package.
+const Provider = "kayak"
+
+var (
+	errAdviceNotFound  = errors.New("advice text not found")
+	errResultsNotFound = errors.New("result section not found")
+	errPriceNotFound   = errors.New("price not found")
+	errBrowser         = errors.New("browser failed")
+	errNavigation      = errors.New("navigation failed")
+)
+
+// failureReason names the reason of a failed fetch for the metrics.
+func failureReason(err error) string {
+	var blocked *BlockedError
+	switch {
+	case errors.As(err, &blocked):
+		return "blocked"
+	case errors.Is(err, errAdviceNotFound):
+		return "advice_not_found"
+	case errors.Is(err, errResultsNotFound):
+		return "results_not_found"
+	case errors.Is(err, errPriceNotFound):
+		return "price_not_found"
+	case errors.Is(err, errBrowser):
+		return "browser"
+	case errors.Is(err, errNavigation):
+		return "navigation"
+	case errors.Is(err, errProxy):
+		return "proxy"
 	}
+	return "unknown"
 }
 
-func getHtml(ctx *context.Context, html *string) {
-	if err := chromedp.Run(*ctx, chromedp.OuterHTML("/", html)); err != nil {
-		log.Fatal(err)
-	}
+func takeScreenshot(ctx *context.Context, buff *[]byte) error {
+	return chromedp.Run(*ctx, chromedp.CaptureScreenshot(buff))
 }
 
+func getHtml(ctx *context.Context, html *string) error {
+	return chromedp.Run(*ctx, chromedp.OuterHTML("/", html))
+}
+
+// takeAndSaveScreenshot saves a screenshot of the page as fname.png. It
+// returns an empty file name when that failed.
 func takeAndSaveScreenshot(ctx *context.Context, fname string) string {
 	var buff []byte
+	l := logging.FromContext(*ctx)
 
 	fname = fmt.Sprintf("%s.png", fname)
 
-	if err := chromedp.Run(*ctx,
-		chromedp.CaptureScreenshot(&buff),
-	); err != nil {
-		log.Fatal(err)
+	if err := takeScreenshot(ctx, &buff); err != nil {
+		l.Warn("Couldn't take screenshot", "err", err)
+		return ""
 	}
 	if err := os.WriteFile(fname, buff, 0o644); err != nil {
-		log.Fatal(err)
+		l.Warn("Couldn't save screenshot", "err", err)
+		return ""
 	}
 
 	return fname
 }
 
-func createFilename(timestamp string, base string, ext string) string {
-	return fmt.Sprintf("%s-%s.%s", timestamp, base, ext)
-}
-
-func writeDebugData(prefix string, screenshot []byte, html string) {
+// writeDebugData saves the screenshot and html of a page in dir, named
+// after the time and prefix. An empty screenshot isn't saved. The
+// returned name lacks the extension.
+func writeDebugData(dir string, prefix string, screenshot []byte, html string) (string, error) {
 	tstamp := time.Now().Format("2006_01_02__15_04_05")
-	htmlFname := createFilename(tstamp, prefix, "html")
-	screenshotFname := createFilename(tstamp, prefix, "png")
+	base := path.Join(dir, fmt.Sprintf("%s-%s", tstamp, prefix))
 
-	if err := os.WriteFile(screenshotFname, screenshot, 0o644); err != nil {
-		log.Fatal(err)
+	if len(screenshot) > 0 {
+		if err := os.WriteFile(base+".png", screenshot, 0o644); err != nil {
+			return "", err
+		}
 	}
-
-	f, err := os.Create(htmlFname)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer f.Close()
-
-	_, err = f.WriteString(html)
-	if err != nil {
-		log.Fatal(err)
+	if err := os.WriteFile(base+".html", []byte(html), 0o644); err != nil {
+		return "", err
 	}
+	return base, nil
 }
 
-func isReady(ctx *context.Context) (bool, error) {
-	retries := MAX_RETRIES
-	sleepMultiplier := 2
+// isReady waits for the advice text and the result list to show up. The
+// advice text is returned even when the result list doesn't, as well as
+// the number of polls that had to be retried. A bot check is reported
+// right away with a *BlockedError.
+func isReady(ctx *context.Context, policy *retry.Policy) (bool, *string, int, error) {
 	var err error
 	var adviceText *string
+	retries := 0
 
-	fmt.Println("Checking if ready...")
+	l := logging.FromContext(*ctx)
+	l.Debug("Checking if ready")
 
-	for retries > 0 {
+	_, span := tracing.Start(*ctx, "isReady advice")
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		span.AddEvent("poll", trace.WithAttributes(attribute.Int("attempt", attempt)))
 		adviceText, err = getAdviceText(ctx)
 
 		if err == nil && !strings.Contains(*adviceText, "load") {
 			break
-		} else {
-			log.Printf("Couldn't find advice text. Retrying in %d seconds... (Retries left: %d)\n", sleepMultiplier, retries)
-			time.Sleep(time.Duration(sleepMultiplier) * time.Second)
+		}
+		if err := checkBlocked(ctx); err != nil {
+			l.Error("Kayak served a bot check", "err", err)
+			tracing.End(span, err)
+			return false, nil, retries, err
+		}
+		if attempt == policy.MaxAttempts {
+			break
+		}
 
-			retries--
-			sleepMultiplier *= 2
+		l.Info("Couldn't find advice text, retrying", "wait", policy.Delay(attempt), "attempt", attempt)
+		metrics.ReadyRetries.WithLabelValues(Provider, "advice").Inc()
+		retries++
+		if err := policy.Sleep(*ctx, attempt); err != nil {
+			break
 		}
 	}
 
 	if adviceText == nil {
-		log.Printf("CRITICAL :: Failed to find advice text. Cannot continue.")
-		return false, errors.New("advice text not found")
+		l.Error("Failed to find advice text, cannot continue")
+		tracing.End(span, errAdviceNotFound)
+		return false, nil, retries, errAdviceNotFound
+	}
+	tracing.End(span, nil)
+
+	_, span = tracing.Start(*ctx, "isReady results")
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		span.AddEvent("poll", trace.WithAttributes(attribute.Int("attempt", attempt)))
+		if countResultList(ctx) > 0 {
+			tracing.End(span, nil)
+			return true, adviceText, retries, nil
+		}
+		if attempt == policy.MaxAttempts {
+			break
+		}
+
+		l.Info("Didn't find results section, retrying", "wait", policy.Delay(attempt), "attempt", attempt)
+		metrics.ReadyRetries.WithLabelValues(Provider, "results").Inc()
+		retries++
+		if err := policy.Sleep(*ctx, attempt); err != nil {
+			break
+		}
 	}
 
-	retries = MAX_RETRIES
-	sleepMultiplier = 2
-	for retries > 0 {
-		nodeCount := countResultList(ctx)
-		if nodeCount > 0 {
-			return true, nil
-		} else {
-			fmt.Printf("Didn't find results section. Retrying in %d seconds... (Retries left: %d)\n", sleepMultiplier, retries)
-			time.Sleep(time.Duration(sleepMultiplier) * time.Second)
+	tracing.End(span, errResultsNotFound)
+	return false, adviceText, retries, errResultsNotFound
+}
+
+// extractOffer reads the price and itinerary of the cheapest result
+// matching the payload's filter from a ready page. excluded is set when no
+// result matches, the cheapest one is returned then. An error wraps
+// errPriceNotFound.
+func extractOffer(ctx *context.Context, market *Market, payload *md.Payload) (float64, *md.Itinerary, bool, error) {
+	l := logging.FromContext(*ctx)
+
+	var bestPrice *string
+	var itinerary *md.Itinerary
+	excluded := false
 
-			retries--
-			sleepMultiplier *= 2
+	results, err := findResults(ctx, market, legDates(payload))
+	if err != nil {
+		l.Warn("Couldn't parse itineraries, falling back to best price only", "err", err)
+		if bestPrice, err = findBestOfferPrice(ctx); err != nil {
+			l.Error("Couldn't find the best price", "err", err)
+			return 0, nil, false, fmt.Errorf("%w: %s", errPriceNotFound, err)
+		}
+	} else {
+		filter := payload.EffectiveFilter()
+		best, ok := selectResult(results, &filter, payload.TripMode())
+		if !ok {
+			l.Info("No result matches the filter", "dates", payload.DateString())
 		}
+		bestPrice = &best.Price
+		itinerary = best.Itinerary
+		excluded = !ok
 	}
 
-	return false, errors.New("result section not found")
+	v, err := market.ParsePrice(*bestPrice)
+	if err != nil {
+		l.Error("Failed to parse price", "text", *bestPrice, "err", err)
+		return 0, nil, false, fmt.Errorf("%w: %s", errPriceNotFound, err)
+	}
+	return v, itinerary, excluded, nil
 }
 
 func CalculateInitialDate(referenceDate time.Time) time.Time {
 	return referenceDate.Add(time.Duration(28) * md.Day)
 }
 
-func AsyncGetOfferForPayloads(inChan chan *md.Payload, outChan chan *md.Offer, sem chan int) {
+// AsyncGetOfferForPayloads fetches the payloads of inChan, at most as
+// many at once as sem has room for. Their spans are children of ctx's.
+// The payloads left over once the daily budget is spent or while Kayak
+// is paused are returned instead of failed, together with the reason. The
+// reason is a *BlockedError when Kayak blocked a payload of this call.
+func AsyncGetOfferForPayloads(ctx context.Context, cfg *Config, inChan chan *md.Payload, outChan chan *md.Offer, sem chan int) ([]*md.Payload, error) {
 	defer close(outChan)
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var deferred []*md.Payload
+	var reason error
 
 	inner := func(v *md.Payload) {
 		defer wg.Done()
 
+		metrics.QueueDepth.Inc()
 		sem <- 1
-		off, _ := GetOfferForPayload(v)
+		metrics.QueueDepth.Dec()
+		off, err := GetOfferForPayload(ctx, cfg, v)
+		var blocked *BlockedError
+		if errors.As(err, &blocked) || errors.Is(err, ratelimit.ErrPaused) || errors.Is(err, ratelimit.ErrBudgetSpent) {
+			metrics.PayloadsDeferred.WithLabelValues(Provider).Inc()
+			mu.Lock()
+			deferred = append(deferred, v)
+			if reason == nil || blocked != nil {
+				reason = err
+			}
+			mu.Unlock()
+		}
 		if off != nil {
 			outChan <- off
 		}
@@ -150,91 +258,273 @@ func AsyncGetOfferForPayloads(inChan chan *md.Payload, outChan chan *md.Offer, s
 	}
 
 	wg.Wait()
+	return deferred, reason
+}
+
+// GetOfferForPayload fetches the offer of the payload. A failed fetch is
+// tried again in a fresh browser as the provider's PayloadRetry allows.
+// Every page load waits for the provider's rate limit. No offer is
+// returned, but ratelimit.ErrBudgetSpent once the daily budget is spent,
+// ratelimit.ErrPaused while Kayak is paused, or a *BlockedError when
+// Kayak served a bot check, which pauses it for the cool-off.
+func GetOfferForPayload(parent context.Context, cfg *Config, payload *md.Payload) (*md.Offer, error) {
+	parent, span := tracing.Start(parent, "payload",
+		attribute.String("search.id", payload.SearchId),
+		attribute.Int("payload.id", payload.Id),
+		attribute.String("payload.dates", payload.DateString()),
+	)
+	defer span.End()
+
+	l := slog.With("search", payload.SearchId, "payload", payload.Id)
+	policy := &cfg.PayloadRetry
+
+	var offer *md.Offer
+	var pinned *proxy.Proxy
+	switched := 0
+	for attempt := 1; ; attempt++ {
+		if err := cfg.wait(parent, l); err != nil {
+			if offer == nil {
+				l.Warn("Not fetching payload", "err", err)
+				span.SetAttributes(attribute.Bool("deferred", true))
+				return nil, err
+			}
+			l.Warn("Not retrying payload", "err", err)
+			break
+		}
+
+		px := pinned
+		if px == nil {
+			var err error
+			if px, err = cfg.nextProxy(); err != nil {
+				// the last proxy was retired by another payload
+				return nil, fmt.Errorf("%w: %s", ratelimit.ErrPaused, err)
+			}
+		}
+		if cfg.ProxyPerPayload {
+			pinned = px
+		}
+
+		// the screenshot of a failed attempt is replaced by the next one's,
+		// even when that one doesn't get to take any
+		if offer != nil {
+			discardScreenshot(offer, l)
+		}
+
+		fetched, err := fetchAttempt(parent, cfg, payload, px, l.With("attempt", attempt))
+		var blocked *BlockedError
+		if errors.As(err, &blocked) {
+			if cfg.proxyBlocked(px, l) && switched+1 < cfg.proxyCount() {
+				// another proxy may get through, so this doesn't count as
+				// an attempt, but the payload goes through every proxy
+				// once at most
+				l.Warn("Blocked by Kayak, trying another proxy", "proxy", px, "reason", blocked.Reason)
+				pinned = nil
+				switched++
+				attempt--
+				continue
+			}
+
+			until := cfg.pause(l)
+			l.Error("Blocked by Kayak, pausing all page loads", "reason", blocked.Reason, "url", blocked.Url, "until", until)
+			metrics.ProviderBlocks.WithLabelValues(Provider).Inc()
+			span.RecordError(err)
+			return nil, err
+		}
+		if errors.Is(err, errProxy) {
+			pinned = nil
+			if cfg.proxyBlocked(px, l) && switched+1 < cfg.proxyCount() {
+				// not the payload's fault, so this doesn't count as an
+				// attempt either
+				l.Warn("Proxy failed, trying another one", "proxy", px, "err", err)
+				switched++
+				attempt--
+				continue
+			}
+		}
+		offer = fetched
+		offer.Attempts = attempt
+		if offer.FetchSuccessful {
+			cfg.proxySucceeded(px)
+		}
+
+		if offer.FetchSuccessful || attempt >= policy.MaxAttempts {
+			break
+		}
+
+		l.Warn("Fetching failed, retrying in a fresh browser", "wait", policy.Delay(attempt), "attempt", attempt)
+		metrics.PayloadRetries.WithLabelValues(Provider).Inc()
+		if err := policy.Sleep(parent, attempt); err != nil {
+			break
+		}
+	}
+
+	span.SetAttributes(attribute.Int("attempts", offer.Attempts), attribute.Bool("successful", offer.FetchSuccessful))
+	return offer, nil
 }
 
-func remove(s []func(*chromedp.ExecAllocator), i int) []func(*chromedp.ExecAllocator) {
-	s[i] = s[len(s)-1]
-	return s[:len(s)-1]
+// discardScreenshot removes the offer's screenshot from the disk.
+func discardScreenshot(offer *md.Offer, l *slog.Logger) {
+	if offer.Screenshot == "" {
+		return
+	}
+	if err := os.Remove(offer.Screenshot); err != nil && !errors.Is(err, os.ErrNotExist) {
+		l.Warn("Couldn't remove screenshot", "file", offer.Screenshot, "err", err)
+	}
+	offer.Screenshot = ""
 }
 
-func GetOfferForPayload(payload *md.Payload) (*md.Offer, error) {
-	fmt.Println(fmt.Sprintf("Getting %s", payload.DateString()))
-	var headless = true
+// fetchAttempt is replaced by the tests.
+var fetchAttempt = fetchOffer
+
+// fetchOffer makes a single attempt at fetching the payload's offer in a
+// new browser, through px unless it's nil. A failed fetch returns an
+// offer too, with an error wrapping errProxy when the proxy failed. Only a
+// bot check returns a *BlockedError instead.
+func fetchOffer(parent context.Context, cfg *Config, payload *md.Payload, px *proxy.Proxy, l *slog.Logger) (*md.Offer, error) {
+	parent, attemptSpan := tracing.Start(parent, "attempt")
+	defer attemptSpan.End()
+
+	l.Info("Getting offer", "dates", payload.DateString())
+	metrics.ScrapeAttempts.WithLabelValues(Provider).Inc()
+	start := time.Now()
+
+	url := buildUrl(&cfg.Market, payload)
+	failed := func(err error, screenshot string, readyRetries int) *md.Offer {
+		tracing.End(attemptSpan, err)
+		metrics.ScrapeFailures.WithLabelValues(Provider, failureReason(err)).Inc()
+		metrics.ScrapeDuration.WithLabelValues(Provider, "failure").Observe(time.Since(start).Seconds())
+
+		return &md.Offer{
+			Url:             url,
+			FromAirport:     payload.FromCity,
+			ToAirport:       payload.ToCity,
+			DepartureDate:   payload.DepartureDate,
+			ReturnDate:      payload.ReturnDate,
+			Legs:            payload.Legs,
+			Price:           -1,
+			Market:          cfg.Market.Name(),
+			Currency:        cfg.Market.Currency,
+			Screenshot:      screenshot,
+			CreatedOn:       time.Now(),
+			FetchSuccessful: false,
+			ReadyRetries:    readyRetries,
+		}
+	}
 
 	userDataDir := path.Join(os.TempDir(), "airliner-chrome"+uuid.NewString())
+	userAgent := cfg.Browser.nextUserAgent()
+	locale, acceptLanguage := cfg.locale()
 
 	opts := append(
-		chromedp.DefaultExecAllocatorOptions[:],
+		cfg.Browser.allocatorOptions(userAgent, locale),
 		chromedp.UserDataDir(userDataDir),
-		chromedp.UserAgent("Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/109.0"),
 	)
-
-	if !headless {
-		// Remove Headless
-		opts = remove(opts, 2)
+	if px != nil {
+		l = l.With("proxy", px)
+		opts = append(opts, chromedp.ProxyServer(px.Server()))
+		attemptSpan.SetAttributes(attribute.String("proxy", px.Server()))
 	}
 
-	alloCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	alloCtx, cancel := chromedp.NewExecAllocator(parent, opts...)
 	defer cancel()
 
-	ctx, cancel := chromedp.NewContext(alloCtx)
+	ctx, cancel := chromedp.NewContext(logging.NewContext(alloCtx, l))
 	defer cancel()
 
-	// create a timeout
+	// the browser only starts with the first run
+	_, span := tracing.Start(ctx, "allocator start")
+	err := chromedp.Run(ctx)
+	tracing.End(span, err)
+	if err != nil {
+		l.Error("Couldn't start browser", "err", err)
+		return failed(fmt.Errorf("%w: %s", errBrowser, err), "", 0), nil
+	}
+
+	if err := authenticateProxy(ctx, px); err != nil {
+		l.Error("Couldn't set up proxy authentication", "err", err)
+		err = fmt.Errorf("%w: %s", errProxy, err)
+		return failed(err, "", 0), err
+	}
+
+	metrics.ActiveTabs.Inc()
+	defer metrics.ActiveTabs.Dec()
+
+	// create a timeout, the tab outlives it to record the page
+	tab := ctx
 	ctx, cancel = context.WithTimeout(ctx, TIMEOUT_MINUTES)
 	defer cancel()
 
-	url := "https://www.kayak.com/flights/" + payload.FromCity + "-" + payload.ToCity + "/" + payload.DateString() + "?sort=price_a"
+	l.Info("Fetching", "url", url)
+	attemptSpan.SetAttributes(attribute.String("url", url))
 
-	if payload.Direct {
-		url += "&fs=stops=~0"
+	// the viewport is set as well, to know what screenshot size to expect
+	_, span = tracing.Start(ctx, "navigate")
+	err = chromedp.Run(ctx,
+		cfg.Browser.emulate(userAgent, locale, acceptLanguage),
+		chromedp.Navigate(url),
+	)
+	tracing.End(span, err)
+	if err != nil {
+		l.Error("Couldn't navigate", "err", err)
+		if px != nil && isProxyFailure(err) {
+			err = fmt.Errorf("%w: %s", errProxy, err)
+			return failed(err, "", 0), err
+		}
+		screenshot := takeAndSaveScreenshot(&ctx, fmt.Sprintf("%d", payload.Id))
+		return failed(fmt.Errorf("%w: %s", errNavigation, err), screenshot, 0), nil
 	}
 
-	log.Printf("Fetching: %s\n", url)
-
-	// set the viewport size, to know what screenshot size to expect
-	width, height := 1024, 768
+	rdy, adviceText, readyRetries, err := isReady(&ctx, &cfg.ReadyRetry)
+	if cfg.RecordDir != "" {
+		recordPage(tab, cfg.RecordDir, &cfg.Market, url, payload)
+	}
 
-	if err := chromedp.Run(ctx,
-		chromedp.Navigate(url),
-		emulation.SetDeviceMetricsOverride(int64(width), int64(height), 1.0, false),
-	); err != nil {
-		log.Fatal(err)
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		failed(err, "", readyRetries)
+		return nil, err
 	}
 
-	rdy, err := isReady(&ctx)
+	_, span = tracing.Start(ctx, "screenshot")
 	screenshot := takeAndSaveScreenshot(&ctx, fmt.Sprintf("%d", payload.Id))
+	span.End()
 
 	if !rdy || err != nil {
-		return &md.Offer{
-			Url:             url,
-			FromAirport:     payload.FromCity,
-			ToAirport:       payload.ToCity,
-			DepartureDate:   payload.DepartureDate,
-			ReturnDate:      payload.ReturnDate,
-			Price:           -1,
-			Screenshot:      screenshot,
-			CreatedOn:       time.Now(),
-			FetchSuccessful: false,
-		}, nil
+		return failed(err, screenshot, readyRetries), nil
 	}
 
-	bestPrice := findBestOfferPrice(&ctx)
+	advice := parseAdvice(*adviceText, &cfg.Market)
+	if advice.Recommendation == "" {
+		l.Warn("Couldn't read Kayak's advice", "market", cfg.Market.Name(), "text", advice.Text)
+	}
 
-	v, err := strconv.ParseFloat(strings.Trim(strings.Replace(*bestPrice, "$", "", -1), " "), 8)
+	ctx, span = tracing.Start(ctx, "price extraction")
+	v, itinerary, excluded, err := extractOffer(&ctx, &cfg.Market, payload)
+	span.SetAttributes(attribute.Float64("price", v), attribute.Bool("excluded", excluded))
+	tracing.End(span, err)
 	if err != nil {
-		fmt.Println("Fatal: Failed to parse float value for price")
+		return failed(err, screenshot, readyRetries), nil
 	}
 
+	metrics.ScrapeSuccesses.WithLabelValues(Provider).Inc()
+	metrics.ScrapeDuration.WithLabelValues(Provider, "success").Observe(time.Since(start).Seconds())
+
 	return &md.Offer{
 		Url:             url,
 		FromAirport:     payload.FromCity,
 		ToAirport:       payload.ToCity,
 		DepartureDate:   payload.DepartureDate,
 		ReturnDate:      payload.ReturnDate,
+		Legs:            payload.Legs,
 		Price:           v,
+		Market:          cfg.Market.Name(),
+		Currency:        cfg.Market.Currency,
 		Screenshot:      screenshot,
 		CreatedOn:       time.Now(),
+		Itinerary:       itinerary,
+		Advice:          advice,
 		FetchSuccessful: true,
+		Excluded:        excluded,
+		ReadyRetries:    readyRetries,
 	}, nil
 }
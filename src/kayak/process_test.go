@@ -0,0 +1,178 @@
+package kayak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	md "airliner/model"
+	"airliner/proxy"
+	"airliner/ratelimit"
+)
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"advice", errAdviceNotFound, "advice_not_found"},
+		{"results", errResultsNotFound, "results_not_found"},
+		{"wrapped", fmt.Errorf("payload 3: %w", errResultsNotFound), "results_not_found"},
+		{"price", fmt.Errorf("%w: couldn't parse price '' of kayak.com", errPriceNotFound), "price_not_found"},
+		{"browser", fmt.Errorf("%w: exec: not found", errBrowser), "browser"},
+		{"navigation", fmt.Errorf("%w: net::ERR_TIMED_OUT", errNavigation), "navigation"},
+		{"other", errors.New("boom"), "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureReason(tt.err); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+// testConfig returns a config fetching without waits through the proxies.
+func testConfig(t *testing.T, proxies string, maxBlocks int) *Config {
+	cfg := DefaultConfig()
+	cfg.RequestsPerMinute = 0
+	cfg.ThinkTime = ratelimit.Range{}
+	cfg.PayloadRetry.BaseDelay = 0
+	cfg.Proxies = proxies
+	cfg.ProxyMaxBlocks = maxBlocks
+	if err := cfg.LoadProxies(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// fakeFetch replaces fetchOffer with one returning the errors of fetch
+// for every attempt, and counts the attempts.
+func fakeFetch(t *testing.T, fetch func(px *proxy.Proxy) error) *int {
+	calls := 0
+	fetchAttempt = func(_ context.Context, cfg *Config, payload *md.Payload, px *proxy.Proxy, _ *slog.Logger) (*md.Offer, error) {
+		calls++
+		if err := fetch(px); errors.Is(err, errProxy) {
+			return &md.Offer{FromAirport: payload.FromCity, Price: -1}, err
+		} else if err != nil {
+			return nil, err
+		}
+		return &md.Offer{FromAirport: payload.FromCity, FetchSuccessful: true}, nil
+	}
+	t.Cleanup(func() { fetchAttempt = fetchOffer })
+	return &calls
+}
+
+func TestGetOfferForPayloadBlocked(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxies   string
+		maxBlocks int
+		calls     int
+	}{
+		{"direct", "", 3, 1},
+		{"proxies retired", "http://a:1,http://b:1", 1, 2},
+		{"proxies never retired", "http://a:1,http://b:1,http://c:1", 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, tt.proxies, tt.maxBlocks)
+			calls := fakeFetch(t, func(*proxy.Proxy) error {
+				return &BlockedError{Url: "https://www.kayak.com/help/bots.html", Reason: "bot check"}
+			})
+
+			offer, err := GetOfferForPayload(context.Background(), cfg, &md.Payload{FromCity: "LIS"})
+			var blocked *BlockedError
+			if offer != nil || !errors.As(err, &blocked) {
+				t.Fatalf("expected a block, got %v, %v", offer, err)
+			}
+			if *calls != tt.calls {
+				t.Errorf("expected %d page loads, got %d", tt.calls, *calls)
+			}
+			if cfg.paused() == nil {
+				t.Errorf("expected Kayak to be paused")
+			}
+		})
+	}
+}
+
+func TestGetOfferForPayloadProxyFailure(t *testing.T) {
+	cfg := testConfig(t, "http://dead:1,http://alive:1", 1)
+	calls := fakeFetch(t, func(px *proxy.Proxy) error {
+		if px.Server() == "http://dead:1" {
+			return fmt.Errorf("%w: net::ERR_PROXY_CONNECTION_FAILED", errProxy)
+		}
+		return nil
+	})
+
+	offer, err := GetOfferForPayload(context.Background(), cfg, &md.Payload{FromCity: "LIS"})
+	if err != nil || !offer.FetchSuccessful {
+		t.Fatalf("expected an offer through the other proxy, got %+v, %v", offer, err)
+	}
+	if *calls != 2 || offer.Attempts != 1 {
+		t.Errorf("expected 2 page loads in 1 attempt, got %d in %d", *calls, offer.Attempts)
+	}
+	if healthy := cfg.proxies.Healthy(); healthy != 1 {
+		t.Errorf("expected the dead proxy to be retired, %d healthy", healthy)
+	}
+
+	// with every proxy dead, the payload fails without pausing Kayak
+	cfg = testConfig(t, "http://dead:1", 0)
+	fakeFetch(t, func(*proxy.Proxy) error {
+		return fmt.Errorf("%w: net::ERR_PROXY_CONNECTION_FAILED", errProxy)
+	})
+	offer, err = GetOfferForPayload(context.Background(), cfg, &md.Payload{FromCity: "LIS"})
+	if err != nil || offer.FetchSuccessful {
+		t.Fatalf("expected a failed offer, got %+v, %v", offer, err)
+	}
+	if cfg.paused() != nil {
+		t.Errorf("expected Kayak not to be paused for a dead proxy")
+	}
+}
+
+func TestIsProxyFailure(t *testing.T) {
+	if !isProxyFailure(errors.New("page load error net::ERR_PROXY_CONNECTION_FAILED")) {
+		t.Errorf("expected a proxy failure")
+	}
+	if isProxyFailure(errors.New("page load error net::ERR_NAME_NOT_RESOLVED")) {
+		t.Errorf("expected no proxy failure")
+	}
+}
+
+func TestGetOfferForPayloadScreenshot(t *testing.T) {
+	cfg := testConfig(t, "", 0)
+	cfg.PayloadRetry.MaxAttempts = 2
+	first := filepath.Join(t.TempDir(), "1.png")
+	if err := os.WriteFile(first, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	fetchAttempt = func(_ context.Context, cfg *Config, payload *md.Payload, px *proxy.Proxy, _ *slog.Logger) (*md.Offer, error) {
+		calls++
+		if calls == 1 {
+			return &md.Offer{FromAirport: payload.FromCity, Screenshot: first}, nil
+		}
+		// the retry failed before it could take a screenshot
+		return &md.Offer{FromAirport: payload.FromCity}, nil
+	}
+	t.Cleanup(func() { fetchAttempt = fetchOffer })
+
+	offer, err := GetOfferForPayload(context.Background(), cfg, &md.Payload{FromCity: "LIS"})
+	if err != nil || offer.Attempts != 2 {
+		t.Fatalf("expected a failed offer after 2 attempts, got %+v, %v", offer, err)
+	}
+	if offer.Screenshot != "" {
+		t.Errorf("expected no screenshot, got %s", offer.Screenshot)
+	}
+	if _, err := os.Stat(first); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the first attempt's screenshot to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package kayak
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/chromedp"
+
+	"airliner/logging"
+	"airliner/proxy"
+)
+
+// errProxy marks a page that couldn't be loaded through the proxy.
+var errProxy = errors.New("proxy failed")
+
+// proxyNetErrors are the network errors of Chrome about a dead, refusing
+// or misconfigured proxy.
+var proxyNetErrors = []string{
+	"ERR_PROXY_CONNECTION_FAILED",
+	"ERR_TUNNEL_CONNECTION_FAILED",
+	"ERR_SOCKS_CONNECTION_FAILED",
+	"ERR_SOCKS_CONNECTION_HOST_UNREACHABLE",
+	"ERR_PROXY_AUTH_UNSUPPORTED",
+	"ERR_PROXY_CERTIFICATE_INVALID",
+	"ERR_NO_SUPPORTED_PROXIES",
+}
+
+// isProxyFailure reports whether a navigation failed because of the proxy.
+func isProxyFailure(err error) bool {
+	for _, e := range proxyNetErrors {
+		if strings.Contains(err.Error(), e) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateProxy answers the proxy's authentication challenges of the
+// browser with the proxy's credentials. Chrome doesn't take them on the
+// command line. Without a proxy or credentials nothing is done.
+func authenticateProxy(ctx context.Context, px *proxy.Proxy) error {
+	if px == nil {
+		return nil
+	}
+	user, password, ok := px.Credentials()
+	if !ok {
+		return nil
+	}
+
+	l := logging.FromContext(ctx)
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		// the listener mustn't block, so the answers are sent apart
+		switch ev := ev.(type) {
+		case *fetch.EventAuthRequired:
+			go func() {
+				response := &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+					Username: user,
+					Password: password,
+				}
+				if err := fetch.ContinueWithAuth(ev.RequestID, response).Do(executor(ctx)); err != nil {
+					l.Warn("Couldn't authenticate with proxy", "err", err)
+				}
+			}()
+		case *fetch.EventRequestPaused:
+			go func() {
+				if err := fetch.ContinueRequest(ev.RequestID).Do(executor(ctx)); err != nil {
+					l.Debug("Couldn't continue request", "err", err)
+				}
+			}()
+		}
+	})
+
+	return chromedp.Run(ctx, fetch.Enable().WithHandleAuthRequests(true))
+}
+
+// executor returns a context to run commands on ctx's tab with from
+// within a listener.
+func executor(ctx context.Context) context.Context {
+	c := chromedp.FromContext(ctx)
+	return cdp.WithExecutor(ctx, c.Target)
+}
@@ -0,0 +1,94 @@
+package kayak
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"airliner/logging"
+	md "airliner/model"
+)
+
+// recording is what a recorded page was loaded for. It is saved as JSON
+// next to the page's html.
+type recording struct {
+	Url     string     `json:"url"`
+	Market  string     `json:"market"`
+	Payload md.Payload `json:"payload"`
+}
+
+// recordTimeout bounds saving a recorded page. The page is recorded after
+// it was polled, when the fetch's own timeout may already be up.
+const recordTimeout = 30 * time.Second
+
+// recordPage saves the html and a screenshot of the current page of the
+// tab in dir, together with the payload it was loaded for. A failure is
+// only logged, so the fetch carries on. A page without its html isn't
+// saved, one without its screenshot is.
+func recordPage(tab context.Context, dir string, market *Market, url string, payload *md.Payload) {
+	l := logging.FromContext(tab)
+	ctx, cancel := context.WithTimeout(tab, recordTimeout)
+	defer cancel()
+
+	var html string
+	var screenshot []byte
+	if err := getHtml(&ctx, &html); err != nil {
+		l.Warn("Couldn't record page, its html is missing", "err", err)
+		return
+	}
+	if err := takeScreenshot(&ctx, &screenshot); err != nil {
+		l.Warn("Recording page without a screenshot", "err", err)
+	}
+
+	prefix := fmt.Sprintf("%s-%d", payload.SearchId, payload.Id)
+	rec := &recording{Url: url, Market: market.Key, Payload: *payload}
+	page, err := saveRecording(dir, prefix, rec, screenshot, html)
+	if err != nil {
+		l.Warn("Couldn't record page", "err", err)
+		return
+	}
+	l.Info("Recorded page", "file", page)
+}
+
+// saveRecording writes the page and its recording and returns the name of
+// the html file.
+func saveRecording(dir string, prefix string, rec *recording, screenshot []byte, html string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	base, err := writeDebugData(dir, strings.Trim(prefix, "-"), screenshot, html)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(base+".json", data, 0o644); err != nil {
+		return "", err
+	}
+	return base + ".html", nil
+}
+
+// loadRecording reads the recording of a page's html file. A page saved
+// without one returns nil.
+func loadRecording(page string) (*recording, error) {
+	data, err := os.ReadFile(strings.TrimSuffix(page, ".html") + ".json")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("invalid recording of %s: %s", page, err)
+	}
+	return &rec, nil
+}
@@ -0,0 +1,72 @@
+package kayak
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	md "airliner/model"
+)
+
+func TestSaveRecording(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pages")
+	rec := &recording{
+		Url:    "https://www.kayak.de/flights/MUC-LIS/2023-03-15/2023-04-01?sort=price_a",
+		Market: "de",
+		Payload: md.Payload{
+			FromCity:      "MUC",
+			ToCity:        "LIS",
+			DepartureDate: createDate("2023-03-15"),
+			ReturnDate:    createDate("2023-04-01"),
+			Filter:        md.Filter{MaxStops: 1, MaxDuration: 12 * time.Hour},
+			Id:            3,
+			SearchId:      "8e21d0c4",
+		},
+	}
+
+	page, err := saveRecording(dir, "8e21d0c4-3", rec, []byte("png"), "<html></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(page, "-8e21d0c4-3.html") {
+		t.Errorf("unexpected page name %s", page)
+	}
+	if html, _ := os.ReadFile(page); string(html) != "<html></html>" {
+		t.Errorf("unexpected html %s", html)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(page, ".html") + ".png"); err != nil {
+		t.Error(err)
+	}
+
+	loaded, err := loadRecording(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Url != rec.Url || loaded.Market != "de" || loaded.Payload.RouteString() != rec.Payload.RouteString() || loaded.Payload.Filter.MaxDuration != 12*time.Hour {
+		t.Errorf("expected %+v, got %+v", rec, loaded)
+	}
+}
+
+func TestLoadRecordingMissing(t *testing.T) {
+	rec, err := loadRecording(filepath.Join(t.TempDir(), "page.html"))
+	if rec != nil || err != nil {
+		t.Errorf("expected no recording, got %+v, %v", rec, err)
+	}
+}
+
+func TestSaveRecordingWithoutScreenshot(t *testing.T) {
+	rec := &recording{Url: "https://www.kayak.com/flights/LIS-MUC/2023-01-01/?sort=price_a", Market: "com"}
+
+	page, err := saveRecording(t.TempDir(), "-1", rec, nil, "<html></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(page); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(page, ".html") + ".png"); !os.IsNotExist(err) {
+		t.Errorf("expected no screenshot, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package kayak
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/chromedp/chromedp"
+
+	"airliner/logging"
+	md "airliner/model"
+)
+
+// Replayed is what the parser found on a recorded page.
+type Replayed struct {
+	// Payload and Market are the ones the page was recorded for, or the
+	// configured market and an empty payload without a recording.
+	Payload md.Payload
+	Market  Market
+	Url     string
+	// Results counts the result nodes of the page.
+	Results      int
+	ReadyRetries int
+	// Err tells why the page wasn't ready, a *BlockedError for a bot
+	// check. Offer is only set for a ready page.
+	Err   error
+	Offer *md.Offer
+}
+
+// Replay runs the readiness check, the result count and the extraction
+// of a fetch against a page saved with RecordDir, loaded from disk. The
+// market and payload are read from the page's recording if there is one.
+// The returned error is about loading the page, not about parsing it.
+func Replay(parent context.Context, cfg *Config, page string) (*Replayed, error) {
+	abs, err := filepath.Abs(page)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replayed{Market: cfg.Market, Url: "file://" + abs}
+	rec, err := loadRecording(abs)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		market, ok := LookupMarket(rec.Market)
+		if !ok {
+			return nil, fmt.Errorf("unknown market '%s' in the recording of %s", rec.Market, page)
+		}
+		r.Market, r.Payload, r.Url = market, rec.Payload, rec.Url
+	}
+
+	l := slog.With("page", page)
+	alloCtx, cancel := chromedp.NewExecAllocator(parent, cfg.Browser.allocatorOptions("", "")...)
+	defer cancel()
+
+	ctx, cancel := chromedp.NewContext(logging.NewContext(alloCtx, l))
+	defer cancel()
+
+	if err := chromedp.Run(ctx, chromedp.Navigate("file://"+abs)); err != nil {
+		return nil, err
+	}
+
+	rdy, adviceText, readyRetries, err := isReady(&ctx, &cfg.ReadyRetry)
+	r.ReadyRetries = readyRetries
+	r.Results = countResultList(&ctx)
+	if !rdy || err != nil {
+		r.Err = err
+		return r, nil
+	}
+
+	v, itinerary, excluded, err := extractOffer(&ctx, &r.Market, &r.Payload)
+	if err != nil {
+		v = -1
+	}
+	r.Offer = &md.Offer{
+		Url:             r.Url,
+		FromAirport:     r.Payload.FromCity,
+		ToAirport:       r.Payload.ToCity,
+		DepartureDate:   r.Payload.DepartureDate,
+		ReturnDate:      r.Payload.ReturnDate,
+		Legs:            r.Payload.Legs,
+		Price:           v,
+		Market:          r.Market.Name(),
+		Currency:        r.Market.Currency,
+		Itinerary:       itinerary,
+		Advice:          parseAdvice(*adviceText, &r.Market),
+		FetchSuccessful: err == nil,
+		Excluded:        excluded,
+		ReadyRetries:    readyRetries,
+	}
+	r.Err = err
+	return r, nil
+}
@@ -0,0 +1,37 @@
+package kayak
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ReadyRetry.MaxAttempts = 1
+
+	r, err := Replay(context.Background(), cfg, "testdata/example_result.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+
+	if r.Results != 15 {
+		t.Errorf("Expected %d to equal: 15", r.Results)
+	}
+	if r.Market.Key != "de" || r.Payload.FromCity != "MUC" {
+		t.Errorf("recording wasn't read, got market %s and payload %+v", r.Market.Key, r.Payload)
+	}
+
+	o := r.Offer
+	if o.Price != 273 || o.Currency != "EUR" || !o.FetchSuccessful || o.Excluded {
+		t.Errorf("unexpected offer %+v", o)
+	}
+	if o.Itinerary == nil || len(o.Itinerary.Legs) != 2 || o.Itinerary.Legs[0].FromAirport != "MUC" {
+		t.Errorf("unexpected itinerary %+v", o.Itinerary)
+	}
+	if o.Advice == nil {
+		t.Errorf("expected the advice to be parsed")
+	}
+}
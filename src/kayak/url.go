@@ -0,0 +1,101 @@
+package kayak
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	md "airliner/model"
+)
+
+// buildUrl returns the url of the payload's results on the market's site.
+func buildUrl(market *Market, payload *md.Payload) string {
+	url := "https://" + market.Domain + "/flights/" + payload.RouteString() + "?sort=price_a"
+
+	filter := payload.EffectiveFilter()
+	if fs := filterString(&filter); fs != "" {
+		url += "&fs=" + fs
+	}
+
+	return url
+}
+
+// filterString translates a filter into Kayak's "fs" query parameter.
+// Restrictions Kayak can't express are left to the post-filter.
+func filterString(f *md.Filter) string {
+	var parts []string
+
+	switch f.MaxStops {
+	case 0:
+		parts = append(parts, "stops=~0")
+	case 1:
+		parts = append(parts, "stops=-2")
+	}
+
+	// Kayak only limits the duration per leg, no leg can be longer than
+	// the total though. Match checks the total on the parsed itinerary.
+	if f.MaxDuration > 0 {
+		parts = append(parts, fmt.Sprintf("legdur=-%d", int(f.MaxDuration.Minutes())))
+	}
+
+	if f.MinLayover > 0 || f.MaxLayover > 0 {
+		v := fmt.Sprintf("%d-", int(f.MinLayover.Minutes()))
+		if f.MaxLayover > 0 {
+			v += fmt.Sprintf("%d", int(f.MaxLayover.Minutes()))
+		}
+		parts = append(parts, "layoverdur="+v)
+	}
+
+	if len(f.ExcludedLayovers) > 0 {
+		parts = append(parts, "layoverair="+joinExcluded(f.ExcludedLayovers))
+	}
+
+	if len(f.Airlines) > 0 {
+		parts = append(parts, "airlines="+strings.Join(f.Airlines, ","))
+	} else if len(f.ExcludedAirlines) > 0 {
+		parts = append(parts, "airlines="+joinExcluded(f.ExcludedAirlines))
+	}
+
+	if v := windowFilter(f.OutboundDeparture, f.ReturnDeparture); v != "" {
+		parts = append(parts, "takeoff="+v)
+	}
+	if v := windowFilter(f.OutboundArrival, f.ReturnArrival); v != "" {
+		parts = append(parts, "landing="+v)
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// windowFilter formats the windows of both legs like "1700,2400__0000,2300".
+func windowFilter(outbound md.TimeWindow, inbound md.TimeWindow) string {
+	if outbound.IsZero() && inbound.IsZero() {
+		return ""
+	}
+
+	v := windowRange(outbound)
+	if !inbound.IsZero() {
+		v += "__" + windowRange(inbound)
+	}
+	return v
+}
+
+// windowRange leaves windows reaching into the next day to the
+// post-filter, Kayak only takes times of a day.
+func windowRange(w md.TimeWindow) string {
+	if w.IsZero() || w.To > md.Day {
+		return "0000,2400"
+	}
+	return fmt.Sprintf("%s,%s", hhmm(w.From), hhmm(w.To))
+}
+
+func hhmm(d time.Duration) string {
+	return fmt.Sprintf("%02d%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func joinExcluded(codes []string) string {
+	excluded := make([]string, len(codes))
+	for i, c := range codes {
+		excluded[i] = "-" + c
+	}
+	return strings.Join(excluded, ",")
+}
@@ -0,0 +1,111 @@
+package kayak
+
+import (
+	"testing"
+	"time"
+
+	md "airliner/model"
+)
+
+func TestBuildUrl(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload md.Payload
+		want    string
+	}{
+		{
+			name: "round trip without filter",
+			payload: md.Payload{
+				FromCity: "LIS", ToCity: "MUC",
+				DepartureDate: createDate("2023-01-01"), ReturnDate: createDate("2023-01-11"),
+				Filter: md.Filter{MaxStops: md.AnyStops},
+			},
+			want: "https://www.kayak.com/flights/LIS-MUC/2023-01-01/2023-01-11?sort=price_a",
+		},
+		{
+			name: "direct single ticket",
+			payload: md.Payload{
+				FromCity: "LIS", ToCity: "MUC",
+				DepartureDate: createDate("2023-01-01"),
+				Direct:        true,
+				Filter:        md.Filter{MaxStops: md.AnyStops},
+			},
+			want: "https://www.kayak.com/flights/LIS-MUC/2023-01-01/?sort=price_a&fs=stops=~0",
+		},
+		{
+			name: "max stops over direct",
+			payload: md.Payload{
+				FromCity: "LIS", ToCity: "MUC",
+				DepartureDate: createDate("2023-01-01"),
+				Direct:        true,
+				Filter:        md.Filter{MaxStops: 1},
+			},
+			want: "https://www.kayak.com/flights/LIS-MUC/2023-01-01/?sort=price_a&fs=stops=-2",
+		},
+		{
+			name: "all filters",
+			payload: md.Payload{
+				FromCity: "LIS", ToCity: "MUC",
+				DepartureDate: createDate("2023-01-01"),
+				Filter: md.Filter{
+					MaxStops:         1,
+					MaxDuration:      12 * time.Hour,
+					MinLayover:       time.Hour,
+					MaxLayover:       4 * time.Hour,
+					ExcludedLayovers: []string{"FRA", "CDG"},
+					ExcludedAirlines: []string{"FR"},
+				},
+			},
+			want: "https://www.kayak.com/flights/LIS-MUC/2023-01-01/?sort=price_a&fs=stops=-2;legdur=-720;layoverdur=60-240;layoverair=-FRA,-CDG;airlines=-FR",
+		},
+		{
+			name: "time windows",
+			payload: md.Payload{
+				FromCity: "LIS", ToCity: "MUC",
+				DepartureDate: createDate("2023-01-06"), ReturnDate: createDate("2023-01-08"),
+				Filter: md.Filter{
+					MaxStops:          md.AnyStops,
+					OutboundDeparture: md.TimeWindow{From: 17 * time.Hour, To: 24 * time.Hour},
+					ReturnArrival:     md.TimeWindow{From: 0, To: 23 * time.Hour},
+				},
+			},
+			want: "https://www.kayak.com/flights/LIS-MUC/2023-01-06/2023-01-08?sort=price_a&fs=takeoff=1700,2400;landing=0000,2400__0000,2300",
+		},
+		{
+			name: "next day and one-way windows",
+			payload: md.Payload{
+				FromCity: "LIS", ToCity: "MUC",
+				DepartureDate: createDate("2023-01-06"),
+				Filter: md.Filter{
+					MaxStops:        md.AnyStops,
+					OutboundArrival: md.TimeWindow{From: 22 * time.Hour, To: md.Day + time.Hour},
+					ReturnArrival:   md.TimeWindow{From: 0, To: 23 * time.Hour},
+				},
+			},
+			want: "https://www.kayak.com/flights/LIS-MUC/2023-01-06/?sort=price_a&fs=landing=0000,2400",
+		},
+	}
+
+	market := markets["com"]
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildUrl(&market, &tt.payload); got != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBuildUrlMarket(t *testing.T) {
+	payload := md.Payload{
+		FromCity: "LIS", ToCity: "MUC",
+		DepartureDate: createDate("2023-01-01"),
+		Filter:        md.Filter{MaxStops: md.AnyStops},
+	}
+
+	market, _ := LookupMarket("de")
+	want := "https://www.kayak.de/flights/LIS-MUC/2023-01-01/?sort=price_a"
+	if got := buildUrl(&market, &payload); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
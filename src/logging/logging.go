@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Options are the logging flags shared by the commands.
+type Options struct {
+	level  *string
+	format *string
+}
+
+func AddFlags(fs *flag.FlagSet) *Options {
+	return &Options{
+		level:  fs.String("log-level", "info", "log level: debug, info, warn or error"),
+		format: fs.String("log-format", "text", "log format: text or json"),
+	}
+}
+
+// Setup installs the default logger configured by the flags. Every line
+// carries the returned run ID, including lines written with the log
+// package.
+func (o *Options) Setup() (string, error) {
+	return Setup(os.Stderr, *o.level, *o.format)
+}
+
+func Setup(w io.Writer, level string, format string) (string, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return "", fmt.Errorf("unknown log level '%s'", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return "", fmt.Errorf("unknown log format '%s'", format)
+	}
+
+	runId := NewId()
+	slog.SetDefault(slog.New(handler).With("run", runId))
+	return runId, nil
+}
+
+// NewId returns a short random ID for runs and searches.
+func NewId() string {
+	return uuid.NewString()[:8]
+}
+
+type loggerKey struct{}
+
+// NewContext returns a context carrying the logger.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger of the context, or the default one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	var buff bytes.Buffer
+
+	runId, err := Setup(&buff, "warn", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slog.Info("filtered")
+	l := slog.With("search", "abc", "payload", 3)
+	FromContext(NewContext(context.Background(), l)).Warn("kept")
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %q", buff.String())
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["msg"] != "kept" || line["run"] != runId || line["search"] != "abc" || line["payload"] != 3.0 {
+		t.Errorf("unexpected line %v", line)
+	}
+
+	buff.Reset()
+	log.Print("from the log package")
+	if buff.Len() != 0 {
+		t.Errorf("log package output should be an info line below the level, got %q", buff.String())
+	}
+}
+
+func TestSetupErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  string
+		format string
+	}{
+		{"unknown level", "verbose", "text"},
+		{"unknown format", "info", "xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Setup(&bytes.Buffer{}, tt.level, tt.format); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestFromContextDefault(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Errorf("expected the default logger")
+	}
+}
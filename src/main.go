@@ -1,167 +1,255 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel/attribute"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	calc "airliner/calculation"
 	db "airliner/database"
-	ky "airliner/kayak"
+	"airliner/logging"
+	"airliner/metrics"
 	md "airliner/model"
+	"airliner/notify"
+	"airliner/ratelimit"
+	"airliner/render"
 	tg "airliner/telegram"
+	"airliner/tracing"
 )
 
 func main() {
-	var wg sync.WaitGroup
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "chart":
+			runChart(os.Args[2:])
+			return
+		case "advise":
+			runAdvise(os.Args[2:])
+			return
+		case "bot":
+			runBot(os.Args[2:])
+			return
+		case "digest":
+			runDigest(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		}
+	}
 
-	var initialDate time.Time
-	var fromcity = flag.String("from", "", "3 letter upercase code for the city flying from.")
-	var tocity = flag.String("to", "", "3 letter upercase code for the city flying to.")
-	var lookahead = flag.Int("look-ahead", -1, "number of days to look ahead")
-	var duration = flag.Int("duration", -1, "journey duration")
-	var concurrency = flag.Int("concurrency", 2, "max num. of concurrent jobs")
-	var startdate = flag.String("start-date", "", "initial day to lookup")
-	var direct = flag.Bool("direct", true, "set to false to look for non-direct flights too")
+	opts := addSearchFlags(flag.CommandLine)
+	var notifystate = flag.String("notify-state", "airliner-notifications.json", "file remembering the last notification per search")
+	var notifyminchange = flag.Float64("notify-min-change", 5, "price change in percent that is notified again before the cooldown expired")
+	var notifycooldown = flag.Duration("notify-cooldown", 24*time.Hour, "time after which an unchanged best offer is notified again")
+	var notifyruns = flag.Bool("notify-runs", true, "set to false to leave the start, best offer and failed offer messages of every run to the digest")
+	var budgetstate = flag.String("budget-state", "airliner-budget.json", "file keeping the page loads per day, deferred payloads and provider pauses")
+	currencyOpts := addCurrencyFlags(flag.CommandLine)
+	var metricsaddr = flag.String("metrics-addr", "", "address to expose Prometheus metrics on during the run, e.g. :9100")
+	logOpts := logging.AddFlags(flag.CommandLine)
+	traceOpts := tracing.AddFlags(flag.CommandLine)
 
 	var client = db.InitDB("test_influxdb.env")
 
 	godotenv.Load()
 	flag.Parse()
 
-	if *fromcity == "" {
-		fmt.Println("ERROR argument --from not supplied")
+	if _, err := logOpts.Setup(); err != nil {
+		fmt.Printf("ERROR %s\n", err)
 		return
 	}
-	if *tocity == "" {
-		fmt.Println("ERROR argument --to not supplied")
+
+	s, err := opts.parse()
+	if err != nil {
+		fmt.Printf("ERROR %s\n", err)
 		return
 	}
-	if *lookahead == -1 {
-		fmt.Println("ERROR argument --look-ahead not supplied")
+	s.template.SearchId = logging.NewId()
+	s.quiet = !*notifyruns
+
+	converter, err := currencyOpts.load()
+	if err != nil {
+		fmt.Printf("ERROR %s\n", err)
 		return
 	}
-	if *duration == -1 {
-		fmt.Println("--duration not supplied, assuming 'single ticket' mode")
+	if err := useConverter(s, converter); err != nil {
+		fmt.Printf("ERROR %s\n", err)
+		return
 	}
 
-	outChan := make(chan *md.Offer)
-	inChan := make(chan *md.Payload)
-	successfullOffers := make([]*md.Offer, 0, 0)
-	failedOffers := make([]*md.Offer, 0, 0)
-	sem := make(chan int, *concurrency)
-
-	fromCity := *fromcity
-	toCity := *tocity
+	shutdownTracing, err := traceOpts.Setup(context.Background())
+	if err != nil {
+		fmt.Printf("ERROR %s\n", err)
+		return
+	}
+	defer shutdownTracing(context.Background())
 
-	if *startdate == "" {
-		initialDate = ky.CalculateInitialDate(time.Now())
-	} else {
-		parsed, err := time.Parse("2006-01-02", *startdate)
-		if err != nil {
-			log.Panicf("Unable to parse --start-date value '%s'. Format should be YYYY-MM-DD.\n", *startdate)
-		}
-		initialDate = parsed
+	if *metricsaddr != "" {
+		metrics.Serve(*metricsaddr)
 	}
-	tripDuration := *duration
-	datesToLookAhead := *lookahead
 
 	bot, err := tg.InitBot()
 	if err != nil {
 		log.Panic(err)
 	}
-	notifyStart(bot)
-
-	wg.Add(2)
-	go ky.CreatePayloads(
-		fromCity, toCity, initialDate, tripDuration, datesToLookAhead, *direct, inChan, &wg,
-	)
-
-	go readAndSaveOffers(
-		outChan, &successfullOffers, &failedOffers, &client, &wg,
-	)
+	notifier := &notify.Telegram{Bot: bot}
 
-	ky.AsyncGetOfferForPayloads(inChan, outChan, sem)
-	wg.Wait()
-
-	if len(successfullOffers) == 0 {
-		msg := "Couldn't get any offers. Something might be wrong."
-		log.Println(msg)
-		notifyError(bot, msg)
-	} else {
-		minOffer := calc.GetMinPriceOffer(successfullOffers)
-		notifyEnd(bot, minOffer)
+	state, err := notify.LoadStateStore(*notifystate, *notifyminchange/100, *notifycooldown)
+	if err != nil {
+		log.Panic(err)
 	}
 
-	for _, o := range failedOffers {
-		notifyFailedOffer(bot, o)
+	s.kayak.Budget, err = ratelimit.LoadBudget(*budgetstate)
+	if err != nil {
+		log.Panic(err)
 	}
 
+	_, successfullOffers, failedOffers := runSearch(context.Background(), s, client, notifier, state, nil)
+
 	cleanupFiles(successfullOffers)
 	cleanupFiles(failedOffers)
 
 }
 
-func notifyStart(bot *tg.Bot) {
-	tg.SendMessage(bot, "Hi there... Query operation starting...")
+func notifyStart(n notify.Notifier) {
+	n.SendMessage("Hi there... Query operation starting...")
+}
+
+func notifyError(n notify.Notifier, msg string) {
+	n.SendMessage(fmt.Sprintf("ERROR: %s", msg))
 }
 
-func notifyError(bot *tg.Bot, msg string) {
-	tg.SendMessage(bot, fmt.Sprintf("ERROR: %s", msg))
+func notifyFailedOffer(n notify.Notifier, offer *md.Offer) {
+	if offer.Screenshot == "" {
+		n.SendMessage("Couldn't fetch offer.")
+		return
+	}
+	n.SendMessage("Couldn't fetch offer. Debug data follows.")
+
+	sendScreenshot(n, offer)
 }
 
-func notifyFailedOffer(bot *tg.Bot, offer *md.Offer) {
-	tg.SendMessage(bot, "Couldn't fetch offer. Debug data follows.")
+// sendScreenshot sends the offer's screenshot, if one could be taken.
+func sendScreenshot(n notify.Notifier, offer *md.Offer) {
+	if offer.Screenshot == "" {
+		return
+	}
 
 	reader, err := os.Open(offer.Screenshot)
 	if err != nil {
-		log.Panic(err)
+		log.Printf("Couldn't send screenshot: %s\n", err)
+		return
 	}
-	tg.SendImage(bot, offer.Screenshot, reader)
+	defer reader.Close()
+	n.SendImage(offer.Screenshot, reader)
 }
 
 func createEndMessage(offer *md.Offer) string {
 	var msgText string
 
-	if offer.ReturnDate.IsZero() {
+	if len(offer.Legs) > 0 {
+		route := make([]string, len(offer.Legs))
+		for i, l := range offer.Legs {
+			route[i] = fmt.Sprintf("%s-%s on %s", l.FromCity, l.ToCity, l.Date.Format("2006-01-02"))
+		}
+		msgText = fmt.Sprintf(
+			"The best multi-city offer is: Price %s, Legs: %s",
+			priceString(offer),
+			strings.Join(route, ", "),
+		)
+	} else if offer.ReturnDate.IsZero() {
 		msgText = fmt.Sprintf(
-			"The best single ticket offer to travel from %s to %s is: Price %.2f, Departure: %s",
+			"The best single ticket offer to travel from %s to %s is: Price %s, Departure: %s",
 			offer.FromAirport,
 			offer.ToAirport,
-			offer.Price,
+			priceString(offer),
 			offer.DepartureDate.Format("2006-01-02"),
 		)
 	} else {
 		msgText = fmt.Sprintf(
-			"The best round trip offer to travel for %d days from %s to %s is: Price %.2f, Departure: %s, Return: %s",
+			"The best round trip offer to travel for %d days from %s to %s is: Price %s, Departure: %s, Return: %s",
 			int(offer.ReturnDate.Sub(offer.DepartureDate).Hours()/24),
 			offer.FromAirport,
 			offer.ToAirport,
-			offer.Price,
+			priceString(offer),
 			offer.DepartureDate.Format("2006-01-02"),
 			offer.ReturnDate.Format("2006-01-02"),
 		)
 	}
 
+	msgText += itineraryString(offer)
+	if offer.Advice != nil && offer.Advice.Text != "" {
+		msgText += fmt.Sprintf("\nKayak's advice: %s", offer.Advice)
+	}
+
 	return msgText
 }
 
-func notifyEnd(bot *tg.Bot, offer *md.Offer) {
-	tg.SendMessage(bot, createEndMessage(offer))
+// priceString formats the offer's price, followed by the original one
+// when it was converted into the reporting currency.
+func priceString(offer *md.Offer) string {
+	if offer.OriginalCurrency == "" {
+		return fmt.Sprintf("%.2f", offer.Price)
+	}
+	return fmt.Sprintf("%.2f %s (%.2f %s)", offer.Price, offer.Currency, offer.OriginalPrice, offer.OriginalCurrency)
+}
 
-	reader, err := os.Open(offer.Screenshot)
+func notifyParetoFront(n notify.Notifier, offers []*md.Offer) {
+	lines := []string{"Offers not beaten on price, travel time and stops at once:"}
+	for _, o := range offers {
+		lines = append(lines, o.String()+itineraryString(o))
+	}
+	n.SendMessage(strings.Join(lines, "\n"))
+}
+
+// itineraryString describes travel time and stops of an offer, or
+// returns an empty string when the itinerary is unknown.
+func itineraryString(offer *md.Offer) string {
+	it := offer.Itinerary
+	if it == nil || len(it.Legs) == 0 {
+		return ""
+	}
+
+	hours := it.Duration().Hours()
+	return fmt.Sprintf(" (%.1fh travel time, max. %d stops)", hours, it.Stops())
+}
+
+func notifyEnd(n notify.Notifier, offer *md.Offer, summary *calc.Summary) {
+	msgText := createEndMessage(offer)
+	if summary != nil {
+		msgText += "\n\n" + summary.String()
+	}
+	n.SendMessage(msgText)
+
+	sendScreenshot(n, offer)
+}
+
+func notifyHeatmap(n notify.Notifier, offers []*md.Offer, best *md.Offer) {
+	img, err := render.EncodePNG(render.Heatmap(offers, best))
 	if err != nil {
-		log.Panic(err)
+		log.Printf("Couldn't render heatmap: %s\n", err)
+		return
 	}
-	tg.SendImage(bot, offer.Screenshot, reader)
+	n.SendImage("heatmap.png", bytes.NewReader(img))
 }
 
 func cleanupFiles(offers []*md.Offer) {
 	for _, v := range offers {
+		if v.Screenshot == "" {
+			continue
+		}
 		err := os.Remove(v.Screenshot)
 
 		if err != nil {
@@ -170,23 +258,105 @@ func cleanupFiles(offers []*md.Offer) {
 	}
 }
 
-func readAndSaveOffers(ch chan *md.Offer, successfulOffers *[]*md.Offer, failedOffers *[]*md.Offer, client *db.DBClient, wg *sync.WaitGroup) {
+// routeName names the route of a search, e.g. "LIS-MUC" or
+// "LIS-MUC,BER-LIS" for multi-city searches.
+func routeName(template *md.Payload) string {
+	if !template.IsMultiCity() {
+		return template.FromCity + "-" + template.ToCity
+	}
+
+	legs := make([]string, len(template.Legs))
+	for i, l := range template.Legs {
+		legs[i] = l.FromCity + "-" + l.ToCity
+	}
+	return strings.Join(legs, ",")
+}
+
+// searchKey identifies a search across runs for the notification state.
+// The start date is left out as it moves with every run by default, the
+// market unless it's kayak.com and the filter unless it restricts more
+// than direct flights, to keep the keys of earlier runs.
+func searchKey(template *md.Payload, tripDurations []int, market string) string {
+	filter := template.EffectiveFilter()
+	key := fmt.Sprintf("%s %v direct=%t", routeName(template), tripDurations, filter.MaxStops == 0)
+	if filter.MaxStops == 0 {
+		filter.MaxStops = md.AnyStops
+	}
+	if f := filter.String(); f != "" {
+		key += " filter=" + f
+	}
+	if market != "" && market != "kayak.com" {
+		key += " market=" + market
+	}
+	return key
+}
+
+// offerDates identifies an offer of a search by its dates.
+func offerDates(offer *md.Offer) string {
+	if len(offer.Legs) > 0 {
+		dates := make([]string, len(offer.Legs))
+		for i, l := range offer.Legs {
+			dates[i] = l.Date.Format("2006-01-02")
+		}
+		return strings.Join(dates, "/")
+	}
+
+	dates := offer.DepartureDate.Format("2006-01-02")
+	if !offer.ReturnDate.IsZero() {
+		dates += "/" + offer.ReturnDate.Format("2006-01-02")
+	}
+	return dates
+}
+
+// splitList turns a comma separated flag value into a list of
+// uppercase codes.
+func splitList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, strings.ToUpper(v))
+		}
+	}
+	return list
+}
+
+func readAndSaveOffers(ctx context.Context, ch chan *md.Offer, successfulOffers *[]*md.Offer, failedOffers *[]*md.Offer, client *db.DBClient, converter *calc.Converter, detector *anomalyDetector, progress func(*md.Offer), l *slog.Logger, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for v := range ch {
-		fmt.Println(v.String())
+		l.Info("Got offer", "offer", v.String(), "successful", v.FetchSuccessful)
+		if converter != nil {
+			if err := converter.ConvertOffer(v); err != nil {
+				l.Error("Couldn't convert the price", "offer", v.String(), "err", err)
+			}
+		}
 		if v.FetchSuccessful {
 			*successfulOffers = append(*successfulOffers, v)
-			saveOfferToDB(client, v)
+			if v.Excluded {
+				l.Info("No result matched the filters, not saving offer", "offer", v.String())
+			} else {
+				if detector != nil {
+					detector.check(v)
+				}
+				_, span := tracing.Start(ctx, "db write", attribute.String("measurement", "airlineOffer"))
+				saveOfferToDB(client, v)
+				span.End()
+			}
 		} else {
 			*failedOffers = append(*failedOffers, v)
+			_, span := tracing.Start(ctx, "db write", attribute.String("measurement", "failedOffer"))
+			saveFailedOfferToDB(client, v)
+			span.End()
+		}
+
+		if progress != nil {
+			progress(v)
 		}
 	}
 }
 
-func saveOfferToDB(client *db.DBClient, offer *md.Offer) {
-
-	db.Write_event_with_fluent_Style(
+func saveFailedOfferToDB(client *db.DBClient, offer *md.Offer) {
+	db.Write_failed_offer(
 		*client,
 		db.AirlineOffer{
 			Url:           offer.Url,
@@ -194,10 +364,81 @@ func saveOfferToDB(client *db.DBClient, offer *md.Offer) {
 			ToAirport:     offer.ToAirport,
 			DepartureDate: offer.DepartureDate,
 			ReturnDate:    offer.ReturnDate,
-			Price:         offer.Price,
 			CreatedOn:     offer.CreatedOn,
+			Market:        offer.Market,
+			Attempts:      offer.Attempts,
+			ReadyRetries:  offer.ReadyRetries,
 		},
 		db.Bucket,
 	)
+}
+
+func saveOfferToDB(client *db.DBClient, offer *md.Offer) {
+	record := db.AirlineOffer{
+		Url:              offer.Url,
+		FromAirport:      offer.FromAirport,
+		ToAirport:        offer.ToAirport,
+		DepartureDate:    offer.DepartureDate,
+		ReturnDate:       offer.ReturnDate,
+		Price:            offer.Price,
+		Market:           offer.Market,
+		Currency:         offer.Currency,
+		OriginalPrice:    offer.OriginalPrice,
+		OriginalCurrency: offer.OriginalCurrency,
+		CreatedOn:        offer.CreatedOn,
+		Legs:             offerLegs(offer),
+		Anomaly:          offer.Anomalous,
+		Attempts:         offer.Attempts,
+		ReadyRetries:     offer.ReadyRetries,
+	}
+
+	if a := offer.Advice; a != nil {
+		record.AdviceText = a.Text
+		record.AdviceRecommendation = a.Recommendation
+		record.AdviceTrend = a.Trend
+		record.AdviceConfidence = a.Confidence
+		record.AdviceWithinDays = a.WithinDays
+	}
+
+	db.Write_event_with_fluent_Style(*client, record, db.Bucket)
+}
+
+func offerLegs(offer *md.Offer) []db.AirlineOfferLeg {
+	var legs []db.AirlineOfferLeg
+	for _, l := range offer.Legs {
+		legs = append(legs, db.AirlineOfferLeg{
+			FromAirport:   l.FromCity,
+			ToAirport:     l.ToCity,
+			DepartureDate: l.Date,
+		})
+	}
+	return legs
+}
 
+// saveSummaryToDB stores the summary with the route, trip mode and
+// currency of the run's best offer.
+func saveSummaryToDB(client *db.DBClient, offer *md.Offer, summary *calc.Summary) {
+	db.Write_run_summary(
+		*client,
+		db.RunSummary{
+			FromAirport:     offer.FromAirport,
+			ToAirport:       offer.ToAirport,
+			TripMode:        offer.TripMode(),
+			Currency:        offer.Currency,
+			Count:           summary.Count,
+			Min:             summary.Min,
+			Max:             summary.Max,
+			Mean:            summary.Mean,
+			Median:          summary.Median,
+			P10:             summary.P10,
+			P25:             summary.P25,
+			P75:             summary.P75,
+			P90:             summary.P90,
+			StdDev:          summary.StdDev,
+			CheapestWeekday: summary.CheapestWeekday.String(),
+			BestGap:         summary.BestGap,
+			CreatedOn:       time.Now(),
+		},
+		db.Bucket,
+	)
 }
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "airliner"
+
+var (
+	ScrapeAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scrape_attempts_total",
+		Help:      "Payloads the scraper started to fetch.",
+	}, []string{"provider"})
+
+	ScrapeSuccesses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scrape_successes_total",
+		Help:      "Payloads fetched successfully.",
+	}, []string{"provider"})
+
+	ScrapeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scrape_failures_total",
+		Help:      "Payloads that couldn't be fetched, by reason.",
+	}, []string{"provider", "reason"})
+
+	ScrapeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scrape_duration_seconds",
+		Help:      "Time spent fetching a payload.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 8),
+	}, []string{"provider", "result"})
+
+	ReadyRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ready_retries_total",
+		Help:      "Polls of a page that wasn't ready yet, by what was missing.",
+	}, []string{"provider", "stage"})
+
+	PayloadRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payload_retries_total",
+		Help:      "Failed payloads fetched again in a fresh browser.",
+	}, []string{"provider"})
+
+	ProviderBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_blocks_total",
+		Help:      "Bot checks served by a provider, each pausing it for the cool-off.",
+	}, []string{"provider"})
+
+	ProxiesRetired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "proxies_retired_total",
+		Help:      "Proxies no longer used as they were blocked repeatedly.",
+	}, []string{"provider"})
+
+	PayloadsDeferred = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payloads_deferred_total",
+		Help:      "Payloads left for the next run as the daily request budget was spent or the provider was paused.",
+	}, []string{"provider"})
+
+	ActiveTabs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "browser_tabs_active",
+		Help:      "Browser tabs currently open.",
+	})
+
+	QueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "payload_queue_depth",
+		Help:      "Payloads waiting for a free browser slot.",
+	})
+
+	NotificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_sent_total",
+		Help:      "Notifications sent, by channel.",
+	}, []string{"channel"})
+
+	DBWriteErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_write_errors_total",
+		Help:      "Failed writes to InfluxDB.",
+	})
+
+	BestPrice = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "best_price",
+		Help:      "Best price of the last run of a route.",
+	}, []string{"route"})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve exposes the metrics at addr under /metrics in the background.
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Metrics server stopped: %s\n", err)
+		}
+	}()
+}
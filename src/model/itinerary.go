@@ -0,0 +1,194 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AnyStops disables the stop count restriction of a Filter.
+const AnyStops = -1
+
+type Layover struct {
+	Airport  string
+	Duration time.Duration // zero when the provider doesn't tell
+}
+
+type Leg struct {
+	FromAirport string
+	ToAirport   string
+	Departure   time.Time
+	Arrival     time.Time
+	Duration    time.Duration
+	Stops       int
+	Layovers    []Layover
+	Airlines    []string
+}
+
+type Itinerary struct {
+	Legs []Leg
+}
+
+// Stops returns the highest number of stops found on any leg.
+func (it *Itinerary) Stops() int {
+	max := 0
+	for _, l := range it.Legs {
+		if l.Stops > max {
+			max = l.Stops
+		}
+	}
+	return max
+}
+
+// Duration returns the added travel time of all legs.
+func (it *Itinerary) Duration() time.Duration {
+	var total time.Duration
+	for _, l := range it.Legs {
+		total += l.Duration
+	}
+	return total
+}
+
+// Filter holds the restrictions an itinerary has to satisfy on top of
+// the plain route and dates. Zero values disable a restriction, except
+// for MaxStops which uses AnyStops.
+type Filter struct {
+	MaxStops         int
+	MaxDuration      time.Duration // total travel time of all legs
+	MinLayover       time.Duration
+	MaxLayover       time.Duration
+	ExcludedLayovers []string
+	Airlines         []string // only accept these airlines
+	ExcludedAirlines []string
+
+	OutboundDeparture TimeWindow
+	OutboundArrival   TimeWindow
+	ReturnDeparture   TimeWindow
+	ReturnArrival     TimeWindow
+}
+
+// String describes the filter's restrictions like the flags setting
+// them, e.g. "stops=1;exclude-airlines=FR". It's empty without any.
+func (f *Filter) String() string {
+	var parts []string
+	if f.MaxStops != AnyStops {
+		parts = append(parts, fmt.Sprintf("stops=%d", f.MaxStops))
+	}
+	if f.MaxDuration > 0 {
+		parts = append(parts, "duration="+f.MaxDuration.String())
+	}
+	if f.MinLayover > 0 || f.MaxLayover > 0 {
+		parts = append(parts, fmt.Sprintf("layover=%s-%s", f.MinLayover, f.MaxLayover))
+	}
+
+	lists := []struct {
+		name string
+		list []string
+	}{
+		{"exclude-layovers", f.ExcludedLayovers},
+		{"airlines", f.Airlines},
+		{"exclude-airlines", f.ExcludedAirlines},
+	}
+	for _, l := range lists {
+		if len(l.list) > 0 {
+			parts = append(parts, l.name+"="+strings.Join(l.list, ","))
+		}
+	}
+
+	windows := []struct {
+		name string
+		w    TimeWindow
+	}{
+		{"depart", f.OutboundDeparture},
+		{"arrive", f.OutboundArrival},
+		{"return-depart", f.ReturnDeparture},
+		{"return-arrive", f.ReturnArrival},
+	}
+	for _, w := range windows {
+		if !w.w.IsZero() {
+			parts = append(parts, w.name+"="+w.w.String())
+		}
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Match reports whether the itinerary's total travel time and every leg
+// satisfy the filter. The return windows only apply to the last leg of
+// a round trip, every leg of another trip mode has the outbound ones.
+func (f *Filter) Match(it *Itinerary, tripMode string) bool {
+	if it == nil {
+		return true
+	}
+	if f.MaxDuration > 0 && it.Duration() > f.MaxDuration {
+		return false
+	}
+
+	for i, l := range it.Legs {
+		if !f.matchLeg(&l) {
+			return false
+		}
+		if !f.matchWindows(&l, tripMode == RoundTrip && i > 0 && i == len(it.Legs)-1) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchWindows checks the leg's times against the outbound windows, or
+// the return windows for the last leg of a round trip. Legs without
+// parsed times always match.
+func (f *Filter) matchWindows(l *Leg, isReturn bool) bool {
+	if l.Departure.IsZero() {
+		return true
+	}
+
+	departure, arrival := f.OutboundDeparture, f.OutboundArrival
+	if isReturn {
+		departure, arrival = f.ReturnDeparture, f.ReturnArrival
+	}
+
+	day := time.Date(l.Departure.Year(), l.Departure.Month(), l.Departure.Day(), 0, 0, 0, 0, l.Departure.Location())
+
+	return departure.Contains(l.Departure.Sub(day)) && arrival.Contains(l.Arrival.Sub(day))
+}
+
+func (f *Filter) matchLeg(l *Leg) bool {
+	if f.MaxStops != AnyStops && l.Stops > f.MaxStops {
+		return false
+	}
+	for _, lo := range l.Layovers {
+		if contains(f.ExcludedLayovers, lo.Airport) {
+			return false
+		}
+		if lo.Duration == 0 {
+			continue
+		}
+		if f.MinLayover > 0 && lo.Duration < f.MinLayover {
+			return false
+		}
+		if f.MaxLayover > 0 && lo.Duration > f.MaxLayover {
+			return false
+		}
+	}
+
+	for _, a := range l.Airlines {
+		if contains(f.ExcludedAirlines, a) {
+			return false
+		}
+		if len(f.Airlines) > 0 && !contains(f.Airlines, a) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(list []string, v string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchLeg is one flight of a multi-city search. Flexibility widens the
+// date by that many days in both directions.
+type SearchLeg struct {
+	FromCity    string
+	ToCity      string
+	Date        time.Time
+	Flexibility int
+}
+
+func (l *SearchLeg) String() string {
+	date := l.Date.Format("2006-01-02")
+
+	switch {
+	case l.Flexibility == 1:
+		date += "-flexible-1day"
+	case l.Flexibility > 1:
+		date += fmt.Sprintf("-flexible-%ddays", l.Flexibility)
+	}
+
+	return fmt.Sprintf("%s-%s/%s", l.FromCity, l.ToCity, date)
+}
+
+// ParseLegs reads legs written like "LIS-MUC:2023-05-01,MUC-LIS:2023-05-10~2",
+// where the optional "~N" suffix sets the leg's date flexibility.
+func ParseLegs(value string) ([]SearchLeg, error) {
+	var legs []SearchLeg
+
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		route, date, found := strings.Cut(v, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid leg '%s', format should be FROM-TO:YYYY-MM-DD", v)
+		}
+
+		from, to, found := strings.Cut(route, "-")
+		if !found || len(from) != 3 || len(to) != 3 {
+			return nil, fmt.Errorf("invalid route '%s', format should be FROM-TO", route)
+		}
+
+		leg := SearchLeg{FromCity: strings.ToUpper(from), ToCity: strings.ToUpper(to)}
+
+		if d, flex, found := strings.Cut(date, "~"); found {
+			n, err := strconv.Atoi(flex)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid flexibility '%s' for leg '%s'", flex, v)
+			}
+			leg.Flexibility = n
+			date = d
+		}
+
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date '%s' for leg '%s', format should be YYYY-MM-DD", date, v)
+		}
+		leg.Date = parsed
+
+		if len(legs) > 0 && leg.Date.Before(legs[len(legs)-1].Date) {
+			return nil, fmt.Errorf("leg '%s' departs before the previous one", v)
+		}
+
+		legs = append(legs, leg)
+	}
+
+	if len(legs) < 2 {
+		return nil, fmt.Errorf("a multi-city search needs at least two legs")
+	}
+
+	return legs, nil
+}
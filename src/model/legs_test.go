@@ -0,0 +1,51 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLegs(t *testing.T) {
+	tests := []struct {
+		value   string
+		route   string
+		wantErr bool
+	}{
+		{"LIS-MUC:2023-05-01,BER-LIS:2023-05-10", "LIS-MUC/2023-05-01/BER-LIS/2023-05-10", false},
+		{"lis-muc:2023-05-01~1,MUC-BER:2023-05-05,BER-LIS:2023-05-10~3", "LIS-MUC/2023-05-01-flexible-1day/MUC-BER/2023-05-05/BER-LIS/2023-05-10-flexible-3days", false},
+		{"LIS-MUC:2023-05-01", "", true},
+		{"LIS-MUC:2023-05-10,MUC-LIS:2023-05-01", "", true},
+		{"LIS-MUC:2023-05-01,MUC-LIS:10.05.2023", "", true},
+		{"LISMUC:2023-05-01,MUC-LIS:2023-05-10", "", true},
+		{"LIS-MUC:2023-05-01~x,MUC-LIS:2023-05-10", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			legs, err := ParseLegs(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLegs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			p := Payload{Legs: legs}
+			if got := p.RouteString(); got != tt.route {
+				t.Errorf("want %s, got %s", tt.route, got)
+			}
+		})
+	}
+}
+
+func TestRouteString(t *testing.T) {
+	p := Payload{
+		FromCity:      "LIS",
+		ToCity:        "MUC",
+		DepartureDate: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	if got := p.RouteString(); got != "LIS-MUC/2023-01-01/" {
+		t.Errorf("want LIS-MUC/2023-01-01/, got %s", got)
+	}
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -14,10 +15,53 @@ type Offer struct {
 	DepartureDate time.Time
 	ReturnDate    time.Time
 	Price         float64
-	Screenshot    string
-	CreatedOn     time.Time
+	// Market is the site the offer was found on, e.g. "kayak.de", and
+	// Currency the ISO 4217 code of its price.
+	Market   string
+	Currency string
+	// OriginalPrice and OriginalCurrency are set when Price was converted
+	// into the reporting currency.
+	OriginalPrice    float64
+	OriginalCurrency string
+
+	Screenshot string
+	CreatedOn  time.Time
+	Itinerary  *Itinerary
+	Legs       []SearchLeg // multi-city searches only
+	Advice     *Advice
 
 	FetchSuccessful bool
+	// Attempts counts the fetches of the payload, ReadyRetries the page
+	// polls of the last one that had to be repeated.
+	Attempts     int
+	ReadyRetries int
+	// Excluded is set when no result on the page satisfied the
+	// payload's filter; Price then belongs to the cheapest result.
+	Excluded bool
+	// Anomalous marks prices far below the route's history.
+	Anomalous bool
+}
+
+// Trip modes of offers and payloads, as stored with the offers.
+const (
+	SingleTrip = "single"
+	RoundTrip  = "round"
+	MultiCity  = "multi"
+)
+
+func tripMode(returnDate time.Time, legs []SearchLeg) string {
+	if len(legs) > 0 {
+		return MultiCity
+	}
+	if returnDate.IsZero() {
+		return SingleTrip
+	}
+	return RoundTrip
+}
+
+// TripMode returns SingleTrip, RoundTrip or MultiCity.
+func (o *Offer) TripMode() string {
+	return tripMode(o.ReturnDate, o.Legs)
 }
 
 func (o *Offer) String() string {
@@ -30,12 +74,64 @@ type Payload struct {
 	DepartureDate time.Time
 	ReturnDate    time.Time
 	Direct        bool
+	Filter        Filter
 	Id            int
+	// SearchId correlates the log lines of all payloads of a search.
+	SearchId string
+
+	// Legs is only set for multi-city searches. The first leg's origin
+	// and date and the last leg's date are mirrored into the fields above.
+	Legs []SearchLeg
+}
+
+// TripMode returns SingleTrip, RoundTrip or MultiCity.
+func (p *Payload) TripMode() string {
+	return tripMode(p.ReturnDate, p.Legs)
+}
+
+func (p *Payload) IsMultiCity() bool {
+	return len(p.Legs) > 0
+}
+
+// EffectiveFilter returns the payload's filter with the Direct flag
+// folded into MaxStops. An explicit MaxStops wins over Direct. Return
+// windows are dropped unless the payload is a round trip.
+func (p *Payload) EffectiveFilter() Filter {
+	f := p.Filter
+	if p.Direct && f.MaxStops == AnyStops {
+		f.MaxStops = 0
+	}
+	if p.TripMode() != RoundTrip {
+		f.ReturnDeparture, f.ReturnArrival = TimeWindow{}, TimeWindow{}
+	}
+	return f
+}
+
+// RouteString returns the route and dates in the form used by Kayak's
+// urls, e.g. "LIS-MUC/2023-01-01/2023-01-11".
+func (p *Payload) RouteString() string {
+	if !p.IsMultiCity() {
+		return p.FromCity + "-" + p.ToCity + "/" + p.DateString()
+	}
+
+	parts := make([]string, len(p.Legs))
+	for i, l := range p.Legs {
+		parts[i] = l.String()
+	}
+	return strings.Join(parts, "/")
 }
 
 func (p *Payload) DateString() string {
 	a := p.DepartureDate.Format("2006-01-02")
 
+	if p.IsMultiCity() {
+		dates := make([]string, len(p.Legs))
+		for i, l := range p.Legs {
+			dates[i] = l.Date.Format("2006-01-02")
+		}
+		return strings.Join(dates, "/")
+	}
+
 	if p.ReturnDate.IsZero() {
 		return fmt.Sprintf("%s/", a)
 
@@ -45,3 +141,40 @@ func (p *Payload) DateString() string {
 		return fmt.Sprintf("%s/%s", a, b)
 	}
 }
+
+const (
+	AdviceBuy  = "buy"
+	AdviceWait = "wait"
+
+	TrendRise   = "rise"
+	TrendDrop   = "drop"
+	TrendStable = "stable"
+)
+
+// Advice is Kayak's price prediction shown next to the results. Fields
+// Kayak didn't mention are left empty.
+type Advice struct {
+	Text           string
+	Recommendation string // AdviceBuy or AdviceWait
+	Trend          string // TrendRise, TrendDrop or TrendStable
+	Confidence     int    // percent
+	WithinDays     int
+}
+
+func (a *Advice) String() string {
+	if a.Recommendation == "" {
+		return a.Text
+	}
+
+	s := a.Recommendation
+	if a.Trend != "" {
+		s += ", prices " + a.Trend
+		if a.WithinDays > 0 {
+			s += fmt.Sprintf(" within %d days", a.WithinDays)
+		}
+	}
+	if a.Confidence > 0 {
+		s += fmt.Sprintf(" (%d%% confidence)", a.Confidence)
+	}
+	return s
+}
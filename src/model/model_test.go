@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTripMode(t *testing.T) {
+	date := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		offer Offer
+		want  string
+	}{
+		{"single", Offer{DepartureDate: date}, SingleTrip},
+		{"round", Offer{DepartureDate: date, ReturnDate: date.Add(7 * Day)}, RoundTrip},
+		{"multi", Offer{DepartureDate: date, ReturnDate: date.Add(7 * Day), Legs: []SearchLeg{{FromCity: "LIS", ToCity: "MUC", Date: date}}}, MultiCity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.offer.TripMode(); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+			p := Payload{DepartureDate: tt.offer.DepartureDate, ReturnDate: tt.offer.ReturnDate, Legs: tt.offer.Legs}
+			if got := p.TripMode(); got != tt.want {
+				t.Errorf("payload: expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFilterString(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"none", Filter{MaxStops: AnyStops}, ""},
+		{"direct", Filter{MaxStops: 0}, "stops=0"},
+		{
+			"all",
+			Filter{
+				MaxStops:          1,
+				MaxDuration:       24 * time.Hour,
+				MaxLayover:        4 * time.Hour,
+				ExcludedLayovers:  []string{"FRA", "CDG"},
+				ExcludedAirlines:  []string{"FR"},
+				OutboundDeparture: TimeWindow{17 * time.Hour, 24 * time.Hour},
+				ReturnArrival:     TimeWindow{0, 23*time.Hour + 30*time.Minute},
+			},
+			"stops=1;duration=24h0m0s;layover=0s-4h0m0s;exclude-layovers=FRA,CDG;exclude-airlines=FR;depart=17:00-24:00;return-arrive=00:00-23:30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a time of day range, both ends given as the offset from
+// midnight of the day a leg departs. The zero value accepts any time.
+type TimeWindow struct {
+	From time.Duration
+	To   time.Duration
+}
+
+func (w TimeWindow) IsZero() bool {
+	return w.From == 0 && w.To == 0
+}
+
+// String returns the window like "17:00-23:59" or "22:00-01:00+1".
+func (w TimeWindow) String() string {
+	return fmt.Sprintf("%s-%s", clock(w.From), clock(w.To))
+}
+
+func clock(d time.Duration) string {
+	if d > Day {
+		return fmt.Sprintf("%02d:%02d+1", int((d - Day).Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// Contains reports whether the offset lies inside the window. Offsets of
+// 24h or more belong to a later day and only match when the window
+// reaches that far.
+func (w TimeWindow) Contains(offset time.Duration) bool {
+	if w.IsZero() {
+		return true
+	}
+	return offset >= w.From && offset <= w.To
+}
+
+// ParseTimeWindow reads windows written like "17:00-23:59". A time
+// followed by "+1", as in "22:00-01:00+1", is on the next day. An empty
+// string returns the zero window.
+func ParseTimeWindow(value string) (TimeWindow, error) {
+	if value == "" {
+		return TimeWindow{}, nil
+	}
+
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return TimeWindow{}, fmt.Errorf("invalid time window '%s', format should be HH:MM-HH:MM", value)
+	}
+
+	from, err := parseClock(parts[0])
+	if err != nil {
+		return TimeWindow{}, err
+	}
+	to, err := parseClock(parts[1])
+	if err != nil {
+		return TimeWindow{}, err
+	}
+
+	if to < from {
+		return TimeWindow{}, errors.New("time window ends before it starts")
+	}
+
+	return TimeWindow{From: from, To: to}, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	text, nextDay := strings.CutSuffix(strings.TrimSpace(value), "+1")
+
+	var h, m int
+	var rest string
+	if n, _ := fmt.Sscanf(text, "%d:%d%s", &h, &m, &rest); n != 2 {
+		return 0, fmt.Errorf("invalid time '%s', format should be HH:MM or HH:MM+1", value)
+	}
+	if h < 0 || h > 24 || m < 0 || m > 59 || h == 24 && (m > 0 || nextDay) {
+		return 0, fmt.Errorf("invalid time '%s'", value)
+	}
+
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+	if nextDay {
+		d += Day
+	}
+	return d, nil
+}
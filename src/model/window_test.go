@@ -0,0 +1,77 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    TimeWindow
+		wantErr bool
+	}{
+		{"", TimeWindow{}, false},
+		{"17:00-23:59", TimeWindow{17 * time.Hour, 23*time.Hour + 59*time.Minute}, false},
+		{"06:30-24:00", TimeWindow{6*time.Hour + 30*time.Minute, 24 * time.Hour}, false},
+		{"17:00", TimeWindow{}, true},
+		{"23:00-17:00", TimeWindow{}, true},
+		{"25:00-26:00", TimeWindow{}, true},
+		{"23:00-24:59", TimeWindow{}, true},
+		{"22:00-01:00+1", TimeWindow{22 * time.Hour, Day + time.Hour}, false},
+		{"00:30+1-02:00+1", TimeWindow{Day + 30*time.Minute, Day + 2*time.Hour}, false},
+		{"22:00-24:00+1", TimeWindow{}, true},
+		{"22:00-01:00+2", TimeWindow{}, true},
+		{"01:00+1-23:00", TimeWindow{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTimeWindow(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimeWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+			if !tt.wantErr && tt.value != "" && got.String() != tt.value {
+				t.Errorf("expected %s to be written back the same, got %s", tt.value, got)
+			}
+		})
+	}
+}
+
+func TestFilterMatchWindows(t *testing.T) {
+	friday := time.Date(2023, 3, 17, 0, 0, 0, 0, time.UTC)
+	sunday := friday.Add(2 * Day)
+
+	it := &Itinerary{Legs: []Leg{
+		{Departure: friday.Add(18 * time.Hour), Arrival: friday.Add(21 * time.Hour)},
+		{Departure: sunday.Add(21 * time.Hour), Arrival: sunday.Add(Day + 30*time.Minute)},
+	}}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		tripMode string
+		want     bool
+	}{
+		{"no windows", Filter{MaxStops: AnyStops}, RoundTrip, true},
+		{"depart after 17:00", Filter{MaxStops: AnyStops, OutboundDeparture: TimeWindow{17 * time.Hour, 24 * time.Hour}}, RoundTrip, true},
+		{"depart in the morning", Filter{MaxStops: AnyStops, OutboundDeparture: TimeWindow{6 * time.Hour, 12 * time.Hour}}, RoundTrip, false},
+		{"land back before 23:00", Filter{MaxStops: AnyStops, ReturnArrival: TimeWindow{0, 23 * time.Hour}}, RoundTrip, false},
+		{"land back before 01:00 next day", Filter{MaxStops: AnyStops, ReturnArrival: TimeWindow{0, Day + time.Hour}}, RoundTrip, true},
+		// the last leg of a multi-city trip is no return leg
+		{"multi-city ignores return windows", Filter{MaxStops: AnyStops, ReturnArrival: TimeWindow{0, 23 * time.Hour}}, MultiCity, true},
+		{"multi-city departs every leg after 17:00", Filter{MaxStops: AnyStops, OutboundDeparture: TimeWindow{17 * time.Hour, 24 * time.Hour}}, MultiCity, true},
+		{"multi-city departs every leg before 20:00", Filter{MaxStops: AnyStops, OutboundDeparture: TimeWindow{0, 20 * time.Hour}}, MultiCity, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(it, tt.tripMode); got != tt.want {
+				t.Errorf("Match() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+
+	"airliner/metrics"
+)
+
+// Email sends HTML mails through the SMTP server configured by SMTP_HOST,
+// SMTP_PORT, SMTP_USERNAME and SMTP_PASSWORD, from EMAIL_FROM to the
+// comma separated addresses in EMAIL_TO.
+type Email struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func InitEmail() (*Email, error) {
+	e := &Email{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("EMAIL_FROM"),
+	}
+	if e.Port == "" {
+		e.Port = "587"
+	}
+	for _, to := range strings.Split(os.Getenv("EMAIL_TO"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			e.To = append(e.To, to)
+		}
+	}
+
+	if e.Host == "" {
+		return nil, errors.New("SMTP_HOST is not set")
+	}
+	if e.From == "" {
+		return nil, errors.New("EMAIL_FROM is not set")
+	}
+	if len(e.To) == 0 {
+		return nil, errors.New("EMAIL_TO is not set")
+	}
+	return e, nil
+}
+
+func (e *Email) SendHTML(subject string, body string) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, e.Host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=\"UTF-8\"\r\n\r\n%s",
+		e.From, strings.Join(e.To, ", "), subject, body)
+
+	if err := smtp.SendMail(e.Host+":"+e.Port, auth, e.From, e.To, []byte(msg)); err != nil {
+		return err
+	}
+	metrics.NotificationsSent.WithLabelValues("email").Inc()
+	return nil
+}
@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"io"
+	"log"
+
+	tg "airliner/telegram"
+)
+
+// Notifier delivers messages and images to the user.
+type Notifier interface {
+	SendMessage(text string)
+	SendImage(name string, reader io.Reader)
+}
+
+// Telegram sends notifications to the chat configured for the bot.
+type Telegram struct {
+	Bot *tg.Bot
+}
+
+func (t *Telegram) SendMessage(text string) {
+	tg.SendMessage(t.Bot, text)
+}
+
+func (t *Telegram) SendImage(name string, reader io.Reader) {
+	tg.SendImage(t.Bot, name, reader)
+}
+
+// Log writes notifications to the log instead of sending them, for
+// setups without a Telegram bot.
+type Log struct{}
+
+func (Log) SendMessage(text string) {
+	log.Println(text)
+}
+
+func (Log) SendImage(name string, reader io.Reader) {
+	log.Printf("Image %s not sent.\n", name)
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sent records the last notification about a search: which offer was
+// sent, e.g. its dates, and at what price.
+type Sent struct {
+	Offer  string    `json:"offer"`
+	Price  float64   `json:"price"`
+	SentOn time.Time `json:"sentOn"`
+}
+
+// StateStore remembers what was last sent per search so repeated runs
+// don't send the same offer again. The state is kept in a JSON file.
+type StateStore struct {
+	path string
+	mu   sync.Mutex
+	sent map[string]Sent
+
+	// MinChange is the relative price change, e.g. 0.05 for 5%, that
+	// makes an offer worth sending again before the cooldown expired.
+	MinChange float64
+	Cooldown  time.Duration
+}
+
+// LoadStateStore reads the state file at path. A missing file starts an
+// empty store.
+func LoadStateStore(path string, minChange float64, cooldown time.Duration) (*StateStore, error) {
+	s := &StateStore{
+		path:      path,
+		sent:      make(map[string]Sent),
+		MinChange: minChange,
+		Cooldown:  cooldown,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.sent); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ShouldSend reports whether a notification about the search identified
+// by key is due. It is when a different offer is reported, when the price
+// changed by more than MinChange or when the cooldown expired.
+func (s *StateStore) ShouldSend(key string, offer string, price float64, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.sent[key]
+	if !ok || last.Offer != offer {
+		return true
+	}
+	if now.Sub(last.SentOn) >= s.Cooldown {
+		return true
+	}
+	if last.Price == 0 {
+		return price != 0
+	}
+	return math.Abs(price-last.Price)/last.Price > s.MinChange
+}
+
+// Remember records a sent notification and writes the state file.
+func (s *StateStore) Remember(key string, offer string, price float64, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent[key] = Sent{Offer: offer, Price: price, SentOn: now}
+
+	data, err := json.MarshalIndent(s.sent, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o644)
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := LoadStateStore(path, 0.05, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.ShouldSend("LIS-MUC", "2023-05-01", 200, now) {
+		t.Errorf("first notification should be sent")
+	}
+	if err := s.Remember("LIS-MUC", "2023-05-01", 200, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload to make sure the state survives between runs.
+	s, err = LoadStateStore(path, 0.05, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		offer string
+		price float64
+		at    time.Time
+		want  bool
+	}{
+		{"same price", "LIS-MUC", "2023-05-01", 200, now.Add(time.Hour), false},
+		{"small change", "LIS-MUC", "2023-05-01", 195, now.Add(time.Hour), false},
+		{"big drop", "LIS-MUC", "2023-05-01", 180, now.Add(time.Hour), true},
+		{"big rise", "LIS-MUC", "2023-05-01", 230, now.Add(time.Hour), true},
+		{"cooldown expired", "LIS-MUC", "2023-05-01", 200, now.Add(25 * time.Hour), true},
+		{"other dates", "LIS-MUC", "2023-05-02", 200, now.Add(time.Hour), true},
+		{"other search", "MUC-LIS", "2023-05-01", 200, now.Add(time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.ShouldSend(tt.key, tt.offer, tt.price, tt.at); got != tt.want {
+				t.Errorf("ShouldSend() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// usage is what a provider spent on a day, what it had to leave for the
+// next run, by search, and until when its requests are paused.
+type usage struct {
+	Day         string              `json:"day"`
+	Used        int                 `json:"used"`
+	Deferred    map[string][]string `json:"deferred,omitempty"`
+	PausedUntil time.Time           `json:"pausedUntil"`
+}
+
+// Budget counts the requests per provider and day, so a daily limit holds
+// across runs. It also acts as the providers' circuit breakers: once a
+// provider blocked us, its requests are paused for a while. The state is
+// kept in a JSON file.
+type Budget struct {
+	path string
+	mu   sync.Mutex
+	used map[string]*usage
+}
+
+// LoadBudget reads the budget file at path. A missing file starts with
+// nothing spent, an empty path keeps the counts in memory only.
+func LoadBudget(path string) (*Budget, error) {
+	b := &Budget{path: path, used: make(map[string]*usage)}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &b.used); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Take spends a request of the provider's budget for the day of now. It
+// reports false when limit requests were spent already; a limit of 0
+// only counts. The error is about saving the file, the request is
+// counted anyway.
+func (b *Budget) Take(provider string, limit int, now time.Time) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u := b.today(provider, now)
+	if limit > 0 && u.Used >= limit {
+		return false, nil
+	}
+	u.Used++
+
+	return true, b.save()
+}
+
+// Used returns the requests the provider spent on the day of now.
+func (b *Budget) Used(provider string, now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.today(provider, now).Used
+}
+
+// Defer remembers items, e.g. payload dates, the search identified by key
+// couldn't fetch, replacing the ones remembered before.
+func (b *Budget) Defer(provider string, key string, items []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u := b.provider(provider)
+	if len(items) == 0 {
+		delete(u.Deferred, key)
+	} else {
+		if u.Deferred == nil {
+			u.Deferred = make(map[string][]string)
+		}
+		u.Deferred[key] = items
+	}
+
+	return b.save()
+}
+
+// Deferred returns the items remembered for the search identified by key.
+func (b *Budget) Deferred(provider string, key string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.provider(provider).Deferred[key]
+}
+
+// Pause opens the provider's circuit breaker: its requests are paused
+// until the given time.
+func (b *Budget) Pause(provider string, until time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u := b.provider(provider)
+	if until.After(u.PausedUntil) {
+		u.PausedUntil = until
+	}
+	return b.save()
+}
+
+// PausedUntil returns until when the provider's requests are paused, or
+// the zero time when they aren't at now.
+func (b *Budget) PausedUntil(provider string, now time.Time) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until := b.provider(provider).PausedUntil; until.After(now) {
+		return until
+	}
+	return time.Time{}
+}
+
+func (b *Budget) provider(provider string) *usage {
+	u, ok := b.used[provider]
+	if !ok {
+		u = &usage{}
+		b.used[provider] = u
+	}
+	return u
+}
+
+// today returns the provider's usage, reset when the day changed.
+func (b *Budget) today(provider string, now time.Time) *usage {
+	u := b.provider(provider)
+	if day := now.Format("2006-01-02"); u.Day != day {
+		u.Day = day
+		u.Used = 0
+	}
+	return u
+}
+
+func (b *Budget) save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(b.used, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(b.path, data, 0o644)
+}
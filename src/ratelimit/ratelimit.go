@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBudgetSpent is returned once the daily request budget is used up.
+	ErrBudgetSpent = errors.New("daily request budget spent")
+	// ErrPaused is returned while a provider's requests are paused after
+	// it blocked one.
+	ErrPaused = errors.New("requests paused after being blocked")
+)
+
+// random is replaced in tests.
+var random = rand.Float64
+
+// Range is a span of durations to pick a random one from, e.g. the
+// think time before a request.
+type Range struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Random returns a duration between Min and Max.
+func (r *Range) Random() time.Duration {
+	if r.Max <= r.Min {
+		return r.Min
+	}
+	return r.Min + time.Duration(random()*float64(r.Max-r.Min))
+}
+
+// String formats the range the way Set reads it.
+func (r *Range) String() string {
+	if r.Min == r.Max {
+		return r.Min.String()
+	}
+	return r.Min.String() + "-" + r.Max.String()
+}
+
+// Set reads a range like "2s-10s", or a fixed duration like "5s".
+func (r *Range) Set(value string) error {
+	minValue, maxValue, ok := strings.Cut(value, "-")
+	if !ok {
+		maxValue = minValue
+	}
+
+	lo, err := time.ParseDuration(strings.TrimSpace(minValue))
+	if err != nil {
+		return fmt.Errorf("invalid duration range '%s': %s", value, err)
+	}
+	hi, err := time.ParseDuration(strings.TrimSpace(maxValue))
+	if err != nil {
+		return fmt.Errorf("invalid duration range '%s': %s", value, err)
+	}
+	if lo < 0 || hi < lo {
+		return fmt.Errorf("invalid duration range '%s'", value)
+	}
+
+	r.Min, r.Max = lo, hi
+	return nil
+}
+
+// Limiter spaces out requests: at most perMinute of them per minute,
+// each one after a random think time.
+type Limiter struct {
+	interval time.Duration
+	think    Range
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewLimiter returns a limiter allowing perMinute requests per minute. A
+// perMinute of 0 doesn't limit the rate, only the think time is waited.
+func NewLimiter(perMinute float64, think Range) *Limiter {
+	l := &Limiter{think: think}
+	if perMinute > 0 {
+		l.interval = time.Duration(float64(time.Minute) / perMinute)
+	}
+	return l
+}
+
+// Wait blocks until the next request may start. It returns early with
+// the context's error when ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	return sleep(ctx, l.reserve(time.Now())+l.think.Random())
+}
+
+// reserve books the next free slot and returns how long it is away.
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := now
+	if l.next.After(now) {
+		start = l.next
+	}
+	l.next = start.Add(l.interval)
+
+	return start.Sub(now)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
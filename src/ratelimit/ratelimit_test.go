@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRangeSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  Range
+		err   bool
+	}{
+		{"range", "2s-10s", Range{2 * time.Second, 10 * time.Second}, false},
+		{"fixed", "5s", Range{5 * time.Second, 5 * time.Second}, false},
+		{"zero", "0s", Range{}, false},
+		{"reversed", "10s-2s", Range{}, true},
+		{"invalid", "soon", Range{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r Range
+			err := r.Set(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error for '%s'", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if r != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, r)
+			}
+		})
+	}
+}
+
+func TestRangeRandom(t *testing.T) {
+	defer func(r func() float64) { random = r }(random)
+
+	r := Range{2 * time.Second, 10 * time.Second}
+
+	random = func() float64 { return 0 }
+	if got := r.Random(); got != 2*time.Second {
+		t.Errorf("expected 2s, got %s", got)
+	}
+	random = func() float64 { return 0.5 }
+	if got := r.Random(); got != 6*time.Second {
+		t.Errorf("expected 6s, got %s", got)
+	}
+}
+
+func TestLimiterReserve(t *testing.T) {
+	l := NewLimiter(6, Range{})
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		at   time.Duration
+		want time.Duration
+	}{
+		{0, 0},
+		{0, 10 * time.Second},
+		{5 * time.Second, 15 * time.Second},
+		{time.Minute, 0},
+	}
+
+	for i, tt := range tests {
+		if got := l.reserve(now.Add(tt.at)); got != tt.want {
+			t.Errorf("request %d: expected to wait %s, got %s", i, tt.want, got)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	day := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	b, err := LoadBudget(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if ok, err := b.Take("kayak", 2, day); !ok || err != nil {
+			t.Fatalf("request %d should be within the budget, got %t, %v", i, ok, err)
+		}
+	}
+	if ok, _ := b.Take("kayak", 2, day); ok {
+		t.Errorf("third request should exceed the budget")
+	}
+	if ok, _ := b.Take("other", 2, day); !ok {
+		t.Errorf("budgets of other providers should be separate")
+	}
+
+	if err := b.Defer("kayak", "LIS-MUC", []string{"2023-06-01"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = LoadBudget(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used := b.Used("kayak", day); used != 2 {
+		t.Errorf("expected 2 requests used after reloading, got %d", used)
+	}
+	if got := b.Deferred("kayak", "LIS-MUC"); !reflect.DeepEqual(got, []string{"2023-06-01"}) {
+		t.Errorf("expected the deferred items after reloading, got %v", got)
+	}
+	if ok, _ := b.Take("kayak", 2, day.Add(24*time.Hour)); !ok {
+		t.Errorf("budget should be reset the next day")
+	}
+
+	b.Defer("kayak", "LIS-MUC", nil)
+	if got := b.Deferred("kayak", "LIS-MUC"); got != nil {
+		t.Errorf("expected no deferred items, got %v", got)
+	}
+}
+
+func TestBudgetPause(t *testing.T) {
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	b, _ := LoadBudget("")
+
+	if until := b.PausedUntil("kayak", now); !until.IsZero() {
+		t.Errorf("expected no pause, got one until %s", until)
+	}
+
+	b.Pause("kayak", now.Add(time.Hour))
+	b.Pause("kayak", now.Add(time.Minute))
+
+	if until := b.PausedUntil("kayak", now); !until.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected a pause until %s, got %s", now.Add(time.Hour), until)
+	}
+	if until := b.PausedUntil("other", now); !until.IsZero() {
+		t.Errorf("expected other providers not to be paused")
+	}
+	if until := b.PausedUntil("kayak", now.Add(2*time.Hour)); !until.IsZero() {
+		t.Errorf("expected the pause to be over after the cool-off")
+	}
+}
@@ -0,0 +1,261 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth  = 860
+	chartHeight = 460
+	marginLeft  = 64
+	marginRight = 170
+	marginTop   = 34
+	marginBot   = 40
+	tickCount   = 5
+)
+
+var palette = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff},
+	{0xff, 0x7f, 0x0e, 0xff},
+	{0x2c, 0xa0, 0x2c, 0xff},
+	{0xd6, 0x27, 0x28, 0xff},
+	{0x94, 0x67, 0xbd, 0xff},
+	{0x8c, 0x56, 0x4b, 0xff},
+	{0xe3, 0x77, 0xc2, 0xff},
+	{0x7f, 0x7f, 0x7f, 0xff},
+	{0xbc, 0xbd, 0x22, 0xff},
+	{0x17, 0xbe, 0xcf, 0xff},
+}
+
+var grid = color.RGBA{0xe4, 0xe4, 0xe4, 0xff}
+
+type Point struct {
+	Time  time.Time
+	Price float64
+}
+
+// Series is a line of the trend chart, e.g. the observed prices of one
+// departure date.
+type Series struct {
+	Label  string
+	Points []Point
+}
+
+// chartLayout maps times and prices onto chart coordinates.
+type chartLayout struct {
+	series []Series
+	// hidden counts the series left out as the palette ran out of colours.
+	hidden   int
+	minTime  time.Time
+	maxTime  time.Time
+	minPrice float64
+	maxPrice float64
+}
+
+func newChartLayout(series []Series) *chartLayout {
+	l := &chartLayout{}
+
+	for _, s := range series {
+		if len(s.Points) == 0 {
+			continue
+		}
+
+		points := append([]Point(nil), s.Points...)
+		sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+		l.series = append(l.series, Series{Label: s.Label, Points: points})
+	}
+
+	// Only as many series as there are colours are drawn, the cheapest by
+	// their latest price, so neither a colour nor the legend runs over.
+	if len(l.series) > len(palette) {
+		cheapest := append([]Series(nil), l.series...)
+		sort.SliceStable(cheapest, func(i, j int) bool { return lastPrice(cheapest[i]) < lastPrice(cheapest[j]) })
+		keep := make(map[string]bool)
+		for _, s := range cheapest[:len(palette)] {
+			keep[s.Label] = true
+		}
+
+		var kept []Series
+		for _, s := range l.series {
+			if keep[s.Label] && len(kept) < len(palette) {
+				kept = append(kept, s)
+			}
+		}
+		l.hidden = len(l.series) - len(kept)
+		l.series = kept
+	}
+
+	for _, s := range l.series {
+		for _, p := range s.Points {
+			if l.minTime.IsZero() || p.Time.Before(l.minTime) {
+				l.minTime = p.Time
+			}
+			if p.Time.After(l.maxTime) {
+				l.maxTime = p.Time
+			}
+			if l.minPrice == 0 || p.Price < l.minPrice {
+				l.minPrice = p.Price
+			}
+			if p.Price > l.maxPrice {
+				l.maxPrice = p.Price
+			}
+		}
+	}
+
+	// Leave some room so flat lines don't stick to the frame.
+	pad := (l.maxPrice - l.minPrice) * 0.05
+	if pad == 0 {
+		pad = 10
+	}
+	l.minPrice -= pad
+	l.maxPrice += pad
+
+	if !l.maxTime.After(l.minTime) {
+		l.maxTime = l.minTime.Add(time.Hour)
+	}
+
+	return l
+}
+
+func lastPrice(s Series) float64 {
+	return s.Points[len(s.Points)-1].Price
+}
+
+// hiddenLabel returns the legend line about the series left out, if any,
+// and its position.
+func (l *chartLayout) hiddenLabel() (string, int) {
+	if l.hidden == 0 {
+		return "", 0
+	}
+	return fmt.Sprintf("+%d more", l.hidden), marginTop + len(l.series)*16 + 10
+}
+
+func (l *chartLayout) x(t time.Time) int {
+	span := l.maxTime.Sub(l.minTime).Seconds()
+	ratio := t.Sub(l.minTime).Seconds() / span
+	return marginLeft + int(ratio*float64(chartWidth-marginLeft-marginRight))
+}
+
+func (l *chartLayout) y(price float64) int {
+	ratio := (price - l.minPrice) / (l.maxPrice - l.minPrice)
+	return chartHeight - marginBot - int(ratio*float64(chartHeight-marginTop-marginBot))
+}
+
+func (l *chartLayout) priceTicks() []float64 {
+	ticks := make([]float64, tickCount)
+	for i := range ticks {
+		ticks[i] = l.minPrice + (l.maxPrice-l.minPrice)*float64(i)/float64(tickCount-1)
+	}
+	return ticks
+}
+
+func (l *chartLayout) timeTicks() []time.Time {
+	ticks := make([]time.Time, tickCount)
+	span := l.maxTime.Sub(l.minTime)
+	for i := range ticks {
+		ticks[i] = l.minTime.Add(span * time.Duration(i) / time.Duration(tickCount-1))
+	}
+	return ticks
+}
+
+// TrendChart draws every series as a line of price over observation time.
+func TrendChart(title string, series []Series) image.Image {
+	l := newChartLayout(series)
+	img := newCanvas(chartWidth, chartHeight)
+
+	drawText(img, marginLeft, marginTop-14, title, foreground)
+
+	for _, p := range l.priceTicks() {
+		y := l.y(p)
+		fillRect(img, image.Rect(marginLeft, y, chartWidth-marginRight, y+1), grid)
+		drawText(img, 4, y+4, fmt.Sprintf("%.0f", p), foreground)
+	}
+	for _, t := range l.timeTicks() {
+		x := l.x(t)
+		fillRect(img, image.Rect(x, marginTop, x+1, chartHeight-marginBot), grid)
+		drawText(img, x-17, chartHeight-marginBot+16, t.Format("01-02"), foreground)
+	}
+
+	for i, s := range l.series {
+		c := palette[i]
+
+		for j := 1; j < len(s.Points); j++ {
+			a, b := s.Points[j-1], s.Points[j]
+			drawLine(img, l.x(a.Time), l.y(a.Price), l.x(b.Time), l.y(b.Price), c)
+		}
+		for _, p := range s.Points {
+			fillRect(img, image.Rect(l.x(p.Time)-2, l.y(p.Price)-2, l.x(p.Time)+2, l.y(p.Price)+2), c)
+		}
+
+		y := marginTop + i*16
+		fillRect(img, image.Rect(chartWidth-marginRight+10, y, chartWidth-marginRight+20, y+10), c)
+		drawText(img, chartWidth-marginRight+26, y+10, s.Label, foreground)
+	}
+	if text, y := l.hiddenLabel(); text != "" {
+		drawText(img, chartWidth-marginRight+10, y, text, foreground)
+	}
+
+	return img
+}
+
+// WriteTrendSVG writes the same chart as TrendChart as an SVG document.
+func WriteTrendSVG(w io.Writer, title string, series []Series) error {
+	l := newChartLayout(series)
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="12">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hex(background))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", marginLeft, marginTop-14, hex(foreground), escape(title))
+
+	for _, p := range l.priceTicks() {
+		y := l.y(p)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n", marginLeft, y, chartWidth-marginRight, y, hex(grid))
+		fmt.Fprintf(&b, `<text x="4" y="%d" fill="%s">%.0f</text>`+"\n", y+4, hex(foreground), p)
+	}
+	for _, t := range l.timeTicks() {
+		x := l.x(t)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n", x, marginTop, x, chartHeight-marginBot, hex(grid))
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", x-17, chartHeight-marginBot+16, hex(foreground), t.Format("01-02"))
+	}
+
+	for i, s := range l.series {
+		c := hex(palette[i])
+
+		coords := make([]string, len(s.Points))
+		for j, p := range s.Points {
+			coords[j] = fmt.Sprintf("%d,%d", l.x(p.Time), l.y(p.Price))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(coords, " "), c)
+		for _, p := range s.Points {
+			fmt.Fprintf(&b, `<circle cx="%d" cy="%d" r="2.5" fill="%s"><title>%s: %.2f</title></circle>`+"\n",
+				l.x(p.Time), l.y(p.Price), c, p.Time.Format("2006-01-02 15:04"), p.Price)
+		}
+
+		y := marginTop + i*16
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`+"\n", chartWidth-marginRight+10, y, c)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", chartWidth-marginRight+26, y+10, hex(foreground), escape(s.Label))
+	}
+	if text, y := l.hiddenLabel(); text != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", chartWidth-marginRight+10, y, hex(foreground), text)
+	}
+
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
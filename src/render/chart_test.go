@@ -0,0 +1,104 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createSeries() []Series {
+	start := time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	return []Series{
+		{Label: "2023-05-01", Points: []Point{
+			{start.Add(48 * time.Hour), 290},
+			{start, 250},
+			{start.Add(24 * time.Hour), 270},
+		}},
+		{Label: "2023-05-02", Points: []Point{
+			{start, 310},
+			{start.Add(48 * time.Hour), 305},
+		}},
+		{Label: "empty"},
+	}
+}
+
+func TestTrendChart(t *testing.T) {
+	img := TrendChart("LIS-MUC", createSeries())
+
+	if want := image.Rect(0, 0, chartWidth, chartHeight); img.Bounds() != want {
+		t.Errorf("expected bounds %v, got %v", want, img.Bounds())
+	}
+}
+
+func TestWriteTrendSVG(t *testing.T) {
+	var buff bytes.Buffer
+	if err := WriteTrendSVG(&buff, "LIS-MUC <history>", createSeries()); err != nil {
+		t.Fatal(err)
+	}
+	svg := buff.String()
+
+	if n := strings.Count(svg, "<polyline"); n != 2 {
+		t.Errorf("expected 2 lines, got %d", n)
+	}
+	if n := strings.Count(svg, "<circle"); n != 5 {
+		t.Errorf("expected 5 points, got %d", n)
+	}
+	if !strings.Contains(svg, "LIS-MUC &lt;history&gt;") {
+		t.Errorf("expected escaped title in %s", svg)
+	}
+}
+
+func TestChartLayoutSortsPoints(t *testing.T) {
+	l := newChartLayout(createSeries())
+
+	if len(l.series) != 2 {
+		t.Fatalf("expected empty series to be dropped, got %d series", len(l.series))
+	}
+
+	points := l.series[0].Points
+	for i := 1; i < len(points); i++ {
+		if points[i].Time.Before(points[i-1].Time) {
+			t.Errorf("points should be sorted by time")
+		}
+	}
+	if l.x(points[0].Time) != marginLeft {
+		t.Errorf("first point should start at the left margin")
+	}
+}
+
+func TestChartLayoutCapsSeries(t *testing.T) {
+	start := time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC)
+	var series []Series
+	for i := 0; i < len(palette)+3; i++ {
+		// the first three dates are the most expensive ones
+		price := float64(500 - i*10)
+		series = append(series, Series{Label: fmt.Sprintf("2023-05-%02d", i+1), Points: []Point{{start, price}}})
+	}
+
+	l := newChartLayout(series)
+	if len(l.series) != len(palette) || l.hidden != 3 {
+		t.Fatalf("expected %d series and 3 hidden, got %d and %d", len(palette), len(l.series), l.hidden)
+	}
+	if l.series[0].Label != "2023-05-04" {
+		t.Errorf("expected the most expensive dates to be left out, got %s first", l.series[0].Label)
+	}
+
+	var buff bytes.Buffer
+	if err := WriteTrendSVG(&buff, "LIS-MUC", series); err != nil {
+		t.Fatal(err)
+	}
+	svg := buff.String()
+	if n := strings.Count(svg, "<polyline"); n != len(palette) {
+		t.Errorf("expected %d lines, got %d", len(palette), n)
+	}
+	if !strings.Contains(svg, "+3 more") {
+		t.Errorf("expected the hidden series in the legend")
+	}
+	if _, y := l.hiddenLabel(); y > chartHeight-marginBot {
+		t.Errorf("legend runs over the chart at %d", y)
+	}
+}
@@ -0,0 +1,114 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"time"
+
+	md "airliner/model"
+)
+
+const (
+	cellWidth    = 64
+	cellHeight   = 22
+	labelWidth   = 110
+	headerHeight = 24
+)
+
+type heatmapCell struct {
+	departure time.Time
+	length    int
+}
+
+// TripLength returns the number of days between the first departure and
+// the return, or zero for single tickets.
+func TripLength(o *md.Offer) int {
+	if o.ReturnDate.IsZero() {
+		return 0
+	}
+	return int(o.ReturnDate.Sub(o.DepartureDate).Hours() / 24)
+}
+
+// Heatmap draws the offers of a run as a price matrix: one row per
+// departure date and one column per trip length. The best offer's cell
+// is outlined, cells without a successful offer are left grey.
+func Heatmap(offers []*md.Offer, best *md.Offer) image.Image {
+	cells := make(map[heatmapCell]*md.Offer)
+	dateSet := make(map[time.Time]bool)
+	lengthSet := make(map[int]bool)
+	min, max := 0.0, 0.0
+
+	for _, o := range offers {
+		c := heatmapCell{o.DepartureDate, TripLength(o)}
+		dateSet[c.departure] = true
+		lengthSet[c.length] = true
+
+		if !o.FetchSuccessful || o.Excluded {
+			continue
+		}
+		if prev, ok := cells[c]; ok && prev.Price <= o.Price {
+			continue
+		}
+		cells[c] = o
+
+		if min == 0 || o.Price < min {
+			min = o.Price
+		}
+		if o.Price > max {
+			max = o.Price
+		}
+	}
+
+	dates := make([]time.Time, 0, len(dateSet))
+	for d := range dateSet {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	lengths := make([]int, 0, len(lengthSet))
+	for l := range lengthSet {
+		lengths = append(lengths, l)
+	}
+	sort.Ints(lengths)
+
+	img := newCanvas(labelWidth+len(lengths)*cellWidth+1, headerHeight+len(dates)*cellHeight+1)
+
+	for col, l := range lengths {
+		header := "single"
+		if l > 0 {
+			header = fmt.Sprintf("%d days", l)
+		}
+		r := image.Rect(labelWidth+col*cellWidth, 0, labelWidth+(col+1)*cellWidth, headerHeight)
+		drawCenteredText(img, r, header, foreground)
+	}
+
+	var bestRect image.Rectangle
+	for row, d := range dates {
+		y := headerHeight + row*cellHeight
+		drawText(img, 4, y+cellHeight-6, d.Format("2006-01-02 Mon"), foreground)
+
+		for col, l := range lengths {
+			r := image.Rect(labelWidth+col*cellWidth, y, labelWidth+(col+1)*cellWidth, y+cellHeight)
+
+			o, ok := cells[heatmapCell{d, l}]
+			if !ok {
+				fillRect(img, r.Inset(1), missing)
+				drawCenteredText(img, r, "-", foreground)
+			} else {
+				fillRect(img, r.Inset(1), scaleColor(o.Price, min, max))
+				drawCenteredText(img, r, fmt.Sprintf("%.0f", o.Price), foreground)
+			}
+
+			if ok && o == best {
+				bestRect = r
+			}
+		}
+	}
+
+	if !bestRect.Empty() {
+		strokeRect(img, bestRect, highlight, 3)
+	}
+
+	return img
+}
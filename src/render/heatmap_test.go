@@ -0,0 +1,56 @@
+package render
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	md "airliner/model"
+)
+
+func TestHeatmap(t *testing.T) {
+	start := time.Date(2023, 3, 13, 0, 0, 0, 0, time.UTC)
+
+	var offers []*md.Offer
+	for i := 0; i < 3; i++ {
+		for _, length := range []int{5, 7} {
+			dep := start.Add(time.Duration(i) * md.Day)
+			offers = append(offers, &md.Offer{
+				DepartureDate:   dep,
+				ReturnDate:      dep.Add(time.Duration(length) * md.Day),
+				Price:           float64(200 + 10*i + length),
+				FetchSuccessful: true,
+			})
+		}
+	}
+	offers[5].FetchSuccessful = false
+	best := offers[0]
+
+	img := Heatmap(offers, best)
+
+	want := image.Rect(0, 0, labelWidth+2*cellWidth+1, headerHeight+3*cellHeight+1)
+	if img.Bounds() != want {
+		t.Fatalf("expected bounds %v, got %v", want, img.Bounds())
+	}
+
+	if c := img.At(labelWidth+1, headerHeight+1); c != highlight {
+		t.Errorf("expected best cell to be highlighted, got %v", c)
+	}
+	if c := img.At(labelWidth+cellWidth+cellWidth/2, headerHeight+2*cellHeight+3); c != missing {
+		t.Errorf("expected failed cell to be grey, got %v", c)
+	}
+	if c := img.At(labelWidth+cellWidth/2, headerHeight+cellHeight+3); c != scaleColor(215, 205, 225) {
+		t.Errorf("expected cell color to follow price, got %v", c)
+	}
+}
+
+func TestTripLength(t *testing.T) {
+	dep := time.Date(2023, 3, 13, 0, 0, 0, 0, time.UTC)
+
+	if l := TripLength(&md.Offer{DepartureDate: dep}); l != 0 {
+		t.Errorf("single ticket should have length 0, got %d", l)
+	}
+	if l := TripLength(&md.Offer{DepartureDate: dep, ReturnDate: dep.Add(7 * md.Day)}); l != 7 {
+		t.Errorf("expected length 7, got %d", l)
+	}
+}
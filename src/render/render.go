@@ -0,0 +1,124 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	foreground = color.RGBA{0x20, 0x20, 0x20, 0xff}
+	missing    = color.RGBA{0xd0, 0xd0, 0xd0, 0xff}
+	highlight  = color.RGBA{0x10, 0x40, 0xd0, 0xff}
+)
+
+var face = basicfont.Face7x13
+
+// EncodePNG returns the image as PNG encoded bytes.
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buff bytes.Buffer
+	if err := png.Encode(&buff, img); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+func newCanvas(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+	return img
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+func strokeRect(img *image.RGBA, r image.Rectangle, c color.Color, width int) {
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width), c)
+	fillRect(img, image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y), c)
+}
+
+// drawText writes text with its baseline starting at x, y.
+func drawText(img *image.RGBA, x int, y int, text string, c color.Color) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  &image.Uniform{c},
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// drawCenteredText writes text centered inside r.
+func drawCenteredText(img *image.RGBA, r image.Rectangle, text string, c color.Color) {
+	width := font.MeasureString(face, text).Ceil()
+	x := r.Min.X + (r.Dx()-width)/2
+	y := r.Min.Y + (r.Dy()+face.Ascent-face.Descent)/2
+	drawText(img, x, y, text, c)
+}
+
+// scaleColor maps v between min and max onto a green to red gradient.
+func scaleColor(v float64, min float64, max float64) color.RGBA {
+	ratio := 0.0
+	if max > min {
+		ratio = math.Max(0, math.Min(1, (v-min)/(max-min)))
+	}
+
+	return color.RGBA{
+		R: uint8(0x40 + ratio*0xb0),
+		G: uint8(0xc0 - ratio*0x80),
+		B: 0x50,
+		A: 0xff,
+	}
+}
+
+// drawLine draws a two pixel wide line using Bresenham's algorithm.
+func drawLine(img *image.RGBA, x0 int, y0 int, x1 int, y1 int, c color.Color) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		img.Set(x0+1, y0, c)
+		img.Set(x0, y0+1, c)
+
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
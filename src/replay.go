@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	ky "airliner/kayak"
+	"airliner/logging"
+)
+
+// runReplay implements "airliner replay": it runs the parser against
+// pages saved with -record and prints what it found on every page.
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	cfg := ky.DefaultConfig()
+	// a saved page doesn't change, polling it again is of no use
+	cfg.ReadyRetry.MaxAttempts = 1
+	fs.Var(&cfg.Market, "kayak-market", "market of pages saved without their .json recording")
+	fs.Var(&cfg.ReadyRetry, "kayak-ready-retry", "retry policy of the readiness polls")
+	fs.StringVar(&cfg.Browser.ChromePath, "kayak-chrome-path", cfg.Browser.ChromePath, "path of the Chrome binary, looked up on the PATH if empty")
+	fs.BoolVar(&cfg.Browser.Headless, "kayak-headless", cfg.Browser.Headless, "set to false to watch the browser at work")
+	logOpts := logging.AddFlags(fs)
+	fs.Parse(args)
+
+	if _, err := logOpts.Setup(); err != nil {
+		fmt.Printf("ERROR %s\n", err)
+		return
+	}
+	if fs.NArg() == 0 {
+		fmt.Println("ERROR no pages given, pass the .html files saved with -record")
+		return
+	}
+
+	for _, page := range fs.Args() {
+		r, err := ky.Replay(context.Background(), cfg, page)
+		if err != nil {
+			fmt.Printf("ERROR %s: %s\n", page, err)
+			continue
+		}
+
+		fmt.Printf("%s\n  market %s, %d results, %d readiness retries\n", page, r.Market.Name(), r.Results, r.ReadyRetries)
+		var blocked *ky.BlockedError
+		switch {
+		case errors.As(r.Err, &blocked):
+			fmt.Printf("  blocked: %s\n", blocked.Reason)
+		case r.Offer == nil:
+			fmt.Printf("  not ready: %s\n", r.Err)
+		default:
+			if r.Err != nil {
+				fmt.Printf("  ERROR %s\n", r.Err)
+			}
+			fmt.Printf("  %s%s\n", r.Offer, itineraryString(r.Offer))
+			if r.Offer.Excluded {
+				fmt.Println("  no result matched the filters")
+			}
+			if r.Offer.Itinerary != nil {
+				for _, leg := range r.Offer.Itinerary.Legs {
+					fmt.Printf("  %s-%s %s - %s, %d stops, %s %s\n", leg.FromAirport, leg.ToAirport, leg.Departure.Format("2006-01-02 15:04"), leg.Arrival.Format("15:04"), leg.Stops, leg.Duration, strings.Join(leg.Airlines, ","))
+				}
+			}
+			if r.Offer.Advice != nil {
+				fmt.Printf("  Kayak's advice: %s\n", r.Offer.Advice)
+			}
+		}
+	}
+}
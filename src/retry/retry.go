@@ -0,0 +1,103 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy describes how often an operation is tried and how long to wait
+// in between. Delays double from BaseDelay up to MaxDelay. Jitter is the
+// fraction, e.g. 0.2, by which every delay is randomly moved up or down.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+// random is replaced in tests.
+var random = rand.Float64
+
+// Delay returns how long to wait after the given failed attempt, counting
+// from 1.
+func (p *Policy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*random() - 1)
+	}
+
+	return time.Duration(d)
+}
+
+// Sleep waits for the delay after the given attempt. It returns early
+// with the context's error when ctx is done.
+func (p *Policy) Sleep(ctx context.Context, attempt int) error {
+	t := time.NewTimer(p.Delay(attempt))
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// String formats the policy the way Set reads it.
+func (p *Policy) String() string {
+	return fmt.Sprintf("attempts=%d,delay=%s,max-delay=%s,jitter=%g", p.MaxAttempts, p.BaseDelay, p.MaxDelay, p.Jitter)
+}
+
+// Set reads a policy like "attempts=5,delay=2s,max-delay=30s,jitter=0.2".
+// Fields left out keep their value, so a policy can be used as a flag
+// with its defaults.
+func (p *Policy) Set(value string) error {
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		key, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return fmt.Errorf("invalid retry policy field '%s'", field)
+		}
+
+		var err error
+		switch key {
+		case "attempts":
+			p.MaxAttempts, err = strconv.Atoi(v)
+			if err == nil && p.MaxAttempts < 1 {
+				err = fmt.Errorf("attempts must be at least 1")
+			}
+		case "delay":
+			p.BaseDelay, err = time.ParseDuration(v)
+		case "max-delay":
+			p.MaxDelay, err = time.ParseDuration(v)
+		case "jitter":
+			p.Jitter, err = strconv.ParseFloat(v, 64)
+			if err == nil && (p.Jitter < 0 || p.Jitter > 1) {
+				err = fmt.Errorf("jitter must be between 0 and 1")
+			}
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid retry policy field '%s': %s", field, err)
+		}
+	}
+
+	return nil
+}
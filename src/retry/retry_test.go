@@ -0,0 +1,91 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	p := Policy{MaxAttempts: 5, BaseDelay: 2 * time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{10, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := p.Delay(tt.attempt); got != tt.want {
+			t.Errorf("attempt %d: expected %s, got %s", tt.attempt, tt.want, got)
+		}
+	}
+}
+
+func TestDelayJitter(t *testing.T) {
+	defer func(r func() float64) { random = r }(random)
+
+	p := Policy{BaseDelay: 10 * time.Second, Jitter: 0.2}
+
+	random = func() float64 { return 0 }
+	if got := p.Delay(1); got != 8*time.Second {
+		t.Errorf("expected 8s, got %s", got)
+	}
+	random = func() float64 { return 1 }
+	if got := p.Delay(1); got != 12*time.Second {
+		t.Errorf("expected 12s, got %s", got)
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  Policy
+		err   bool
+	}{
+		{"all fields", "attempts=3,delay=1s,max-delay=5s,jitter=0.1", Policy{3, time.Second, 5 * time.Second, 0.1}, false},
+		{"keeps defaults", "attempts=2", Policy{2, 2 * time.Second, 32 * time.Second, 0}, false},
+		{"unknown field", "tries=2", Policy{}, true},
+		{"no attempts", "attempts=0", Policy{}, true},
+		{"jitter too big", "jitter=2", Policy{}, true},
+		{"invalid delay", "delay=soon", Policy{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Policy{MaxAttempts: 5, BaseDelay: 2 * time.Second, MaxDelay: 32 * time.Second}
+			err := p.Set(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err == nil && p != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, p)
+			}
+		})
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	p := Policy{MaxAttempts: 3, BaseDelay: 1500 * time.Millisecond, MaxDelay: time.Minute, Jitter: 0.25}
+
+	var q Policy
+	if err := q.Set(p.String()); err != nil || q != p {
+		t.Errorf("expected %+v, got %+v (%v)", p, q, err)
+	}
+}
+
+func TestSleepCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := Policy{BaseDelay: time.Hour}
+	if err := p.Sleep(ctx, 1); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	calc "airliner/calculation"
+	db "airliner/database"
+	ky "airliner/kayak"
+	"airliner/metrics"
+	md "airliner/model"
+	"airliner/notify"
+	"airliner/ratelimit"
+	"airliner/tracing"
+)
+
+// searchFlags are the command line flags describing a search. The HTTP
+// server accepts the same names as parameters.
+type searchFlags struct {
+	fromcity           *string
+	tocity             *string
+	lookahead          *int
+	duration           *int
+	durations          *string
+	concurrency        *int
+	startdate          *string
+	direct             *bool
+	maxstops           *int
+	maxduration        *time.Duration
+	minlayover         *time.Duration
+	maxlayover         *time.Duration
+	excludelayovers    *string
+	airlines           *string
+	excludeairlines    *string
+	departwindow       *string
+	arrivewindow       *string
+	returndepartwindow *string
+	returnarrivewindow *string
+	hourcost           *float64
+	stopcost           *float64
+	preferreddeparture *string
+	departurecost      *float64
+	preferredairlines  *string
+	airlinebonus       *float64
+	pareto             *bool
+	anomalythreshold   *float64
+	anomalyhistory     *int
+	legs               *string
+	kayak              *ky.Config
+}
+
+func addSearchFlags(fs *flag.FlagSet) *searchFlags {
+	kayak := ky.DefaultConfig()
+	kayak.AddFlags(fs)
+	fs.StringVar(&kayak.RecordDir, "record", "", "directory to save the html and a screenshot of every page loaded in, to run \"airliner replay\" on")
+
+	return &searchFlags{
+		fromcity:           fs.String("from", "", "3 letter upercase code for the city flying from."),
+		tocity:             fs.String("to", "", "3 letter upercase code for the city flying to."),
+		lookahead:          fs.Int("look-ahead", -1, "number of days to look ahead"),
+		duration:           fs.Int("duration", -1, "journey duration"),
+		durations:          fs.String("durations", "", "comma separated journey durations to compare, e.g. 5,7,10"),
+		concurrency:        fs.Int("concurrency", 2, "max num. of concurrent jobs"),
+		startdate:          fs.String("start-date", "", "initial day to lookup"),
+		direct:             fs.Bool("direct", true, "set to false to look for non-direct flights too"),
+		maxstops:           fs.Int("max-stops", md.AnyStops, "maximum number of stops per leg (0, 1 or 2), overrides --direct"),
+		maxduration:        fs.Duration("max-duration", 0, "maximum total travel time of all legs, e.g. 24h"),
+		minlayover:         fs.Duration("min-layover", 0, "minimum layover time, e.g. 1h"),
+		maxlayover:         fs.Duration("max-layover", 0, "maximum layover time, e.g. 4h"),
+		excludelayovers:    fs.String("exclude-layovers", "", "comma separated airport codes to avoid as layover"),
+		airlines:           fs.String("airlines", "", "comma separated airline codes to fly with exclusively"),
+		excludeairlines:    fs.String("exclude-airlines", "", "comma separated airline codes to avoid"),
+		departwindow:       fs.String("depart-window", "", "time window for the outbound departure, e.g. 17:00-23:59"),
+		arrivewindow:       fs.String("arrive-window", "", "time window for the outbound arrival, e.g. 00:00-23:00 or 22:00-01:00+1 for the next day"),
+		returndepartwindow: fs.String("return-depart-window", "", "time window for the return departure of a round trip"),
+		returnarrivewindow: fs.String("return-arrive-window", "", "time window for the return arrival of a round trip"),
+		hourcost:           fs.Float64("hour-cost", 0, "score: price added per hour of travel time"),
+		stopcost:           fs.Float64("stop-cost", 0, "score: price added per stop"),
+		preferreddeparture: fs.String("preferred-departure", "", "score: preferred outbound departure window, e.g. 08:00-20:00"),
+		departurecost:      fs.Float64("departure-cost", 0, "score: price added per hour departing outside --preferred-departure"),
+		preferredairlines:  fs.String("preferred-airlines", "", "score: comma separated airline codes to prefer"),
+		airlinebonus:       fs.Float64("airline-bonus", 0, "score: price subtracted when flying only with --preferred-airlines"),
+		pareto:             fs.Bool("pareto", false, "report all offers not beaten on price, travel time and stops at once"),
+		anomalythreshold:   fs.Float64("anomaly-threshold", 3.5, "alert right away about prices this many MADs below the route's median, 0 disables"),
+		anomalyhistory:     fs.Int("anomaly-history", 90, "days of history to compare prices with"),
+		legs:               fs.String("legs", "", "multi-city legs instead of --from/--to, e.g. LIS-MUC:2023-05-01,BER-LIS:2023-05-10~1"),
+		kayak:              kayak,
+	}
+}
+
+// search is a validated set of search flags.
+type search struct {
+	template         md.Payload
+	tripDurations    []int
+	lookahead        int
+	concurrency      int
+	scoring          calc.Scoring
+	pareto           bool
+	anomalythreshold float64
+	anomalyhistory   int
+	kayak            *ky.Config
+	// converter, if not nil, converts prices into the reporting currency.
+	converter *calc.Converter
+	// quiet leaves out the start, best offer and failed offer messages
+	// of the run, for deployments reporting through the digest. Errors
+	// and price alerts are still sent.
+	quiet bool
+}
+
+func (f *searchFlags) parse() (*search, error) {
+	fromCity := *f.fromcity
+	toCity := *f.tocity
+
+	var legs []md.SearchLeg
+	if *f.legs != "" {
+		parsed, err := md.ParseLegs(*f.legs)
+		if err != nil {
+			return nil, err
+		}
+		legs = parsed
+		fromCity = legs[0].FromCity
+		toCity = legs[0].ToCity
+	}
+
+	if fromCity == "" {
+		return nil, errors.New("argument --from not supplied")
+	}
+	if toCity == "" {
+		return nil, errors.New("argument --to not supplied")
+	}
+	if *f.lookahead == -1 {
+		return nil, errors.New("argument --look-ahead not supplied")
+	}
+	if *f.duration == -1 && *f.durations == "" && legs == nil {
+		slog.Info("--duration not supplied, assuming 'single ticket' mode")
+	}
+
+	var initialDate time.Time
+	if *f.startdate == "" {
+		initialDate = ky.CalculateInitialDate(time.Now())
+	} else {
+		parsed, err := time.Parse("2006-01-02", *f.startdate)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse --start-date value '%s'. Format should be YYYY-MM-DD", *f.startdate)
+		}
+		initialDate = parsed
+	}
+
+	windows := make([]md.TimeWindow, 4)
+	for i, v := range []string{*f.departwindow, *f.arrivewindow, *f.returndepartwindow, *f.returnarrivewindow} {
+		w, err := md.ParseTimeWindow(v)
+		if err != nil {
+			return nil, err
+		}
+		windows[i] = w
+	}
+
+	departurePreference, err := md.ParseTimeWindow(*f.preferreddeparture)
+	if err != nil {
+		return nil, err
+	}
+
+	tripDurations := []int{*f.duration}
+	if *f.durations != "" {
+		tripDurations = nil
+		for _, v := range strings.Split(*f.durations, ",") {
+			d, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid --durations value '%s'", v)
+			}
+			tripDurations = append(tripDurations, d)
+		}
+	}
+
+	s := &search{
+		template: md.Payload{
+			FromCity:      fromCity,
+			ToCity:        toCity,
+			DepartureDate: initialDate,
+			Direct:        *f.direct,
+			Filter: md.Filter{
+				MaxStops:         *f.maxstops,
+				MaxDuration:      *f.maxduration,
+				MinLayover:       *f.minlayover,
+				MaxLayover:       *f.maxlayover,
+				ExcludedLayovers: splitList(*f.excludelayovers),
+				Airlines:         splitList(*f.airlines),
+				ExcludedAirlines: splitList(*f.excludeairlines),
+
+				OutboundDeparture: windows[0],
+				OutboundArrival:   windows[1],
+				ReturnDeparture:   windows[2],
+				ReturnArrival:     windows[3],
+			},
+		},
+		tripDurations: tripDurations,
+		lookahead:     *f.lookahead,
+		concurrency:   *f.concurrency,
+		scoring: calc.Scoring{
+			HourCost:           *f.hourcost,
+			StopCost:           *f.stopcost,
+			PreferredDeparture: departurePreference,
+			DepartureCost:      *f.departurecost,
+			PreferredAirlines:  splitList(*f.preferredairlines),
+			AirlineBonus:       *f.airlinebonus,
+		},
+		pareto:           *f.pareto,
+		anomalythreshold: *f.anomalythreshold,
+		anomalyhistory:   *f.anomalyhistory,
+		kayak:            f.kayak,
+	}
+	if legs != nil {
+		s.template.Legs = legs
+		s.template.DepartureDate = legs[0].Date
+	}
+
+	if err := f.kayak.LoadProxies(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// tripMode returns the trip mode of the search's payloads.
+func (s *search) tripMode() string {
+	switch {
+	case s.template.IsMultiCity():
+		return md.MultiCity
+	case s.tripDurations[0] > 0:
+		return md.RoundTrip
+	}
+	return md.SingleTrip
+}
+
+// currency returns the currency of the search's prices, once converted.
+func (s *search) currency() string {
+	if s.converter != nil {
+		return s.converter.To
+	}
+	return s.kayak.Market.Currency
+}
+
+// runSearch fetches the offers of every payload of the search, saves them
+// and notifies about the best one. progress, if not nil, is called with
+// every offer as soon as it was fetched. Screenshots are left on disk for
+// the caller to clean up. Log lines carry the template's SearchId.
+func runSearch(ctx context.Context, s *search, client db.DBClient, notifier notify.Notifier, state *notify.StateStore, progress func(*md.Offer)) (best *md.Offer, successfullOffers []*md.Offer, failedOffers []*md.Offer) {
+	var wg sync.WaitGroup
+	l := slog.With("search", s.template.SearchId)
+	l.Info("Starting search", "route", routeName(&s.template), "lookAhead", s.lookahead, "durations", s.tripDurations)
+
+	ctx, span := tracing.Start(ctx, "run",
+		attribute.String("search.id", s.template.SearchId),
+		attribute.String("route", routeName(&s.template)),
+		attribute.Int("lookAhead", s.lookahead),
+	)
+	defer span.End()
+
+	outChan := make(chan *md.Offer)
+	created := make(chan *md.Payload)
+	inChan := make(chan *md.Payload)
+	sem := make(chan int, s.concurrency)
+
+	if !s.quiet {
+		notifyStart(notifier)
+	}
+
+	key := searchKey(&s.template, s.tripDurations, s.kayak.Market.Name())
+	var leftOver []string
+	if s.kayak.Budget != nil {
+		leftOver = s.kayak.Budget.Deferred(ky.Provider, key)
+	}
+
+	wg.Add(2)
+	go ky.CreatePayloadsForTripLengths(
+		s.template, s.tripDurations, s.lookahead, created, &wg,
+	)
+	go prioritize(created, inChan, leftOver)
+
+	var detector *anomalyDetector
+	if s.anomalythreshold > 0 {
+		detector = newAnomalyDetector(client, notifier, state, key, db.HistoryQuery{
+			FromAirport: s.template.FromCity,
+			ToAirport:   s.template.ToCity,
+			TripMode:    s.tripMode(),
+			Currency:    s.currency(),
+			Since:       time.Now().Add(-time.Duration(s.anomalyhistory) * 24 * time.Hour),
+		}, s.anomalythreshold, l)
+	}
+
+	go readAndSaveOffers(
+		ctx, outChan, &successfullOffers, &failedOffers, &client, s.converter, detector, progress, l, &wg,
+	)
+
+	deferred, reason := ky.AsyncGetOfferForPayloads(ctx, s.kayak, inChan, outChan, sem)
+	wg.Wait()
+
+	if s.kayak.Budget != nil {
+		dates := make([]string, len(deferred))
+		for i, p := range deferred {
+			dates[i] = p.DateString()
+		}
+		if err := s.kayak.Budget.Defer(ky.Provider, key, dates); err != nil {
+			l.Error("Couldn't save the deferred payloads", "err", err)
+		}
+	}
+	var blocked *ky.BlockedError
+	if errors.As(reason, &blocked) {
+		msg := fmt.Sprintf("Provider blocked: %s served a %s, pausing it for %s. %d payloads deferred to the next run.", ky.Provider, blocked.Reason, s.kayak.CoolOff, len(deferred))
+		l.Error(msg)
+		notifier.SendMessage(msg)
+	} else if errors.Is(reason, ratelimit.ErrPaused) {
+		// the block was notified already
+		l.Warn("Payloads deferred to the next run", "count", len(deferred), "err", reason)
+	} else if len(deferred) > 0 {
+		msg := fmt.Sprintf("%d payloads deferred to the next run: %s.", len(deferred), reason)
+		l.Warn(msg)
+		notifier.SendMessage(msg)
+	}
+
+	if len(successfullOffers) == 0 && len(deferred) > 0 {
+		l.Info("No offers fetched before the payloads were deferred, nothing to report")
+	} else if len(successfullOffers) == 0 {
+		msg := "Couldn't get any offers. Something might be wrong."
+		l.Error(msg)
+		notifyError(notifier, msg)
+	} else if best = calc.GetBestOffer(successfullOffers, &s.scoring); best == nil {
+		msg := "None of the offers matched the filters."
+		l.Warn(msg)
+		notifyError(notifier, msg)
+	} else {
+		metrics.BestPrice.WithLabelValues(routeName(&s.template)).Set(best.Price)
+		span.SetAttributes(attribute.Float64("bestPrice", best.Price))
+
+		summary := calc.Summarize(successfullOffers)
+		if summary != nil {
+			saveSummaryToDB(&client, best, summary)
+		}
+
+		if s.quiet {
+			l.Info("Best offer found, leaving it to the digest", "price", best.Price)
+		} else if state.ShouldSend(key, offerDates(best), best.Price, time.Now()) {
+			if s.pareto {
+				notifyParetoFront(notifier, calc.ParetoFront(successfullOffers))
+			}
+			notifyEnd(notifier, best, summary)
+			notifyHeatmap(notifier, append(successfullOffers, failedOffers...), best)
+
+			if err := state.Remember(key, offerDates(best), best.Price, time.Now()); err != nil {
+				l.Error("Couldn't save notification state", "err", err)
+			}
+		} else {
+			l.Info("Best offer didn't change since the last notification, not sending it again")
+		}
+	}
+
+	if !s.quiet {
+		for _, o := range failedOffers {
+			notifyFailedOffer(notifier, o)
+		}
+	}
+
+	return best, successfullOffers, failedOffers
+}
+
+// prioritize passes on the payloads of in, the ones with the given dates
+// first. Those were deferred by the last run, so a spent budget doesn't
+// leave the same dates out every time.
+func prioritize(in <-chan *md.Payload, out chan<- *md.Payload, dates []string) {
+	defer close(out)
+
+	if len(dates) == 0 {
+		for p := range in {
+			out <- p
+		}
+		return
+	}
+
+	first := make(map[string]bool, len(dates))
+	for _, d := range dates {
+		first[d] = true
+	}
+
+	var rest []*md.Payload
+	for p := range in {
+		if first[p.DateString()] {
+			out <- p
+		} else {
+			rest = append(rest, p)
+		}
+	}
+	for _, p := range rest {
+		out <- p
+	}
+}
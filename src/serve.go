@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"airliner/api"
+	calc "airliner/calculation"
+	db "airliner/database"
+	ky "airliner/kayak"
+	"airliner/logging"
+	"airliner/metrics"
+	md "airliner/model"
+	"airliner/notify"
+	"airliner/ratelimit"
+	"airliner/render"
+	tg "airliner/telegram"
+	"airliner/tracing"
+)
+
+// pipelineRunner runs API searches through the same pipeline as the
+// command line.
+type pipelineRunner struct {
+	client    db.DBClient
+	notifier  notify.Notifier
+	state     *notify.StateStore
+	converter *calc.Converter
+	quiet     bool
+	// kayak holds the operator's provider settings, the rate limiter and
+	// the proxies every search shares.
+	kayak *ky.Config
+}
+
+// searchParams are the search flags the API accepts. Flags naming files,
+// binaries or the provider's limits are left to the server's operator.
+var searchParams = map[string]bool{
+	"from": true, "to": true, "legs": true,
+	"look-ahead": true, "start-date": true, "duration": true, "durations": true,
+	"direct": true, "max-stops": true, "max-duration": true, "min-layover": true, "max-layover": true,
+	"exclude-layovers": true, "airlines": true, "exclude-airlines": true,
+	"depart-window": true, "arrive-window": true, "return-depart-window": true, "return-arrive-window": true,
+	"hour-cost": true, "stop-cost": true, "preferred-departure": true, "departure-cost": true,
+	"preferred-airlines": true, "airline-bonus": true, "pareto": true,
+	"kayak-market": true,
+}
+
+// parseParams reads the parameters like command line flags of a search.
+// Only searchParams are accepted; the other provider settings are taken
+// from kayak, whose market is the default.
+func parseParams(params map[string]string, kayak *ky.Config) (*search, error) {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	opts := addSearchFlags(fs)
+	opts.kayak.Market = kayak.Market
+	for name, value := range params {
+		if !searchParams[name] {
+			return nil, fmt.Errorf("unknown parameter '%s'", name)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	s, err := opts.parse()
+	if err != nil {
+		return nil, err
+	}
+	s.kayak.Share(kayak)
+	return s, nil
+}
+
+func (p *pipelineRunner) Validate(params map[string]string) error {
+	s, err := parseParams(params, p.kayak)
+	if err != nil {
+		return err
+	}
+	return useConverter(s, p.converter)
+}
+
+func (p *pipelineRunner) Run(id string, params map[string]string, progress func(*md.Offer)) *md.Offer {
+	s, err := parseParams(params, p.kayak)
+	if err != nil {
+		log.Panic(err)
+	}
+	s.template.SearchId = id
+	s.quiet = p.quiet
+	if err := useConverter(s, p.converter); err != nil {
+		log.Panic(err)
+	}
+
+	best, _, _ := runSearch(context.Background(), s, p.client, p.notifier, p.state, progress)
+	return best
+}
+
+type dbHistory struct {
+	client db.DBClient
+}
+
+func (h *dbHistory) Offers(q db.HistoryQuery) ([]db.AirlineOffer, error) {
+	return db.Read_offer_history(h.client, q, db.Bucket)
+}
+
+func (h *dbHistory) Failures(q db.HistoryQuery) ([]db.AirlineOffer, error) {
+	return db.Read_failed_offers(h.client, q, db.Bucket)
+}
+
+func (h *dbHistory) PriceHistory(from string, to string, days int) (string, []render.Series, error) {
+	return loadPriceHistory(h.client, from, to, days)
+}
+
+// runServe implements "airliner serve": it serves the HTTP API, the
+// dashboard and the Prometheus metrics until the process is stopped.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var addr = fs.String("addr", ":8080", "address to listen on")
+	var screenshots = fs.String("screenshots", "screenshots", "directory keeping the screenshots of API searches")
+	var keep = fs.Int("keep", 20, "number of finished searches to keep")
+	var watches = fs.String("watches", "airliner-watches.json", "file keeping the searches added as watches")
+	var watchinterval = fs.Duration("watch-interval", 24*time.Hour, "time between two runs of a watch")
+	var notifystate = fs.String("notify-state", "airliner-notifications.json", "file remembering the last notification per search")
+	var notifyminchange = fs.Float64("notify-min-change", 5, "price change in percent that is notified again before the cooldown expired")
+	var notifycooldown = fs.Duration("notify-cooldown", 24*time.Hour, "time after which an unchanged best offer is notified again")
+	var notifyruns = fs.Bool("notify-runs", true, "set to false to leave the start, best offer and failed offer messages of every run to the digest")
+	var budgetstate = fs.String("budget-state", "airliner-budget.json", "file keeping the page loads per day, deferred payloads and provider pauses")
+	currencyOpts := addCurrencyFlags(fs)
+	kayak := ky.DefaultConfig()
+	kayak.AddFlags(fs)
+	logOpts := logging.AddFlags(fs)
+	traceOpts := tracing.AddFlags(fs)
+	fs.Parse(args)
+
+	if _, err := logOpts.Setup(); err != nil {
+		fmt.Printf("ERROR %s\n", err)
+		return
+	}
+
+	shutdownTracing, err := traceOpts.Setup(context.Background())
+	if err != nil {
+		fmt.Printf("ERROR %s\n", err)
+		return
+	}
+	defer shutdownTracing(context.Background())
+
+	client := db.InitDB("test_influxdb.env")
+	defer client.Close()
+	godotenv.Load()
+
+	var notifier notify.Notifier = notify.Log{}
+	if bot, err := tg.InitBot(); err != nil {
+		log.Printf("Telegram disabled: %s\n", err)
+	} else {
+		notifier = &notify.Telegram{Bot: bot}
+	}
+
+	state, err := notify.LoadStateStore(*notifystate, *notifyminchange/100, *notifycooldown)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err := kayak.LoadProxies(); err != nil {
+		log.Panic(err)
+	}
+	kayak.Budget, err = ratelimit.LoadBudget(*budgetstate)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	converter, err := currencyOpts.load()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	watchStore, err := api.LoadWatchStore(*watches)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	server := api.NewServer(
+		&pipelineRunner{client: client, notifier: notifier, state: state, converter: converter, quiet: !*notifyruns, kayak: kayak},
+		&dbHistory{client: client},
+		*screenshots,
+		*keep,
+	)
+	server.Watch(watchStore, *watchinterval)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/", server)
+
+	log.Printf("Listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
@@ -8,6 +8,9 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"strings"
+
+	"airliner/metrics"
 )
 
 var chatID = int64(0)
@@ -48,6 +51,7 @@ func SendImage(bot *tgbotapi.BotAPI, filename string, reader io.Reader) {
 	imgMsg := tgbotapi.NewPhoto(chatID, file)
 	_, err := bot.Send(imgMsg)
 	handleError(err)
+	metrics.NotificationsSent.WithLabelValues("telegram").Inc()
 }
 
 func SendMessage(bot *tgbotapi.BotAPI, msgText string) {
@@ -55,4 +59,46 @@ func SendMessage(bot *tgbotapi.BotAPI, msgText string) {
 		tgbotapi.NewMessage(chatID, msgText),
 	)
 	handleError(err)
+	metrics.NotificationsSent.WithLabelValues("telegram").Inc()
+}
+
+type Command struct {
+	Name string
+	Args []string
+}
+
+// ListenForCommands returns the commands sent to the bot, e.g. "/chart LIS MUC".
+// Messages from chats other than TELEGRAM_CHAT_ID are ignored.
+func ListenForCommands(bot *tgbotapi.BotAPI) <-chan Command {
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+
+	commands := make(chan Command)
+	updates := bot.GetUpdatesChan(u)
+
+	go func() {
+		defer close(commands)
+		for update := range updates {
+			msg := update.Message
+			if msg == nil || !msg.IsCommand() || msg.Chat.ID != chatID {
+				continue
+			}
+			commands <- Command{
+				Name: msg.Command(),
+				Args: strings.Fields(msg.CommandArguments()),
+			}
+		}
+	}()
+
+	return commands
+}
+
+// SendHTMLMessage sends a message formatted with Telegram's HTML subset.
+func SendHTMLMessage(bot *tgbotapi.BotAPI, msgText string) {
+	msg := tgbotapi.NewMessage(chatID, msgText)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	_, err := bot.Send(msg)
+	handleError(err)
+	metrics.NotificationsSent.WithLabelValues("telegram").Inc()
 }
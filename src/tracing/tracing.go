@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const name = "airliner"
+
+// Options are the tracing flags shared by the commands.
+type Options struct {
+	exporter *string
+	endpoint *string
+}
+
+func AddFlags(fs *flag.FlagSet) *Options {
+	return &Options{
+		exporter: fs.String("trace-exporter", "none", "where to export traces: none, stdout or otlp"),
+		endpoint: fs.String("trace-endpoint", "", "OTLP/HTTP endpoint, e.g. http://localhost:4318 (default from OTEL_EXPORTER_OTLP_ENDPOINT)"),
+	}
+}
+
+// Setup installs the global tracer provider configured by the flags. The
+// returned function flushes the remaining spans and must be called before
+// the process exits.
+func (o *Options) Setup(ctx context.Context) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch *o.exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		exporter, err = newOTLPExporter(ctx, *o.endpoint)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter '%s'", *o.exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", name))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newOTLPExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	var opts []otlptracehttp.Option
+
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid trace endpoint '%s'", endpoint)
+		}
+		opts = append(opts, otlptracehttp.WithEndpoint(u.Host))
+		if u.Path != "" && u.Path != "/" {
+			opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+		}
+		if u.Scheme == "http" {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+	}
+
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// Start starts a span of the airliner tracer.
+func Start(ctx context.Context, spanName string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(name).Start(ctx, spanName, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
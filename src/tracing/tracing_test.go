@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown exporter", []string{"-trace-exporter", "jaeger"}},
+		{"invalid endpoint", []string{"-trace-exporter", "otlp", "-trace-endpoint", "localhost"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			opts := AddFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := opts.Setup(context.Background()); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	ctx, run := Start(context.Background(), "run", attribute.String("route", "LIS-MUC"))
+	_, step := Start(ctx, "navigate")
+	End(step, errors.New("timeout"))
+	End(run, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	nav, parent := spans[0], spans[1]
+	if nav.Name != "navigate" || nav.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Errorf("navigate should be a child of run: %+v", nav)
+	}
+	if nav.Status.Code != codes.Error || len(nav.Events) != 1 {
+		t.Errorf("expected the error to be recorded: %+v", nav.Status)
+	}
+	if parent.Status.Code != codes.Unset || parent.Attributes[0] != attribute.String("route", "LIS-MUC") {
+		t.Errorf("unexpected run span %+v", parent)
+	}
+}